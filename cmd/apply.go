@@ -0,0 +1,315 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// manifest declares the desired state of static lists:
+//
+//	lists:
+//	  - id: 113124
+//	    name: Best of 2024
+//	    movies: [tt0133093, 603]
+//	    shows: [tt0903747]
+type manifest struct {
+	Lists []manifestList `yaml:"lists"`
+}
+
+type manifestList struct {
+	ID int `yaml:"id"`
+	// Name renames the list when set and different.
+	Name   string   `yaml:"name"`
+	Movies []string `yaml:"movies"`
+	Shows  []string `yaml:"shows"`
+}
+
+// applyItem is a planItem of one of the lists in the manifest.
+type applyItem struct {
+	ListID   int `json:"list_id" yaml:"list_id"`
+	planItem `yaml:",inline"`
+}
+
+// listPlan is what apply would change in one list.
+type listPlan struct {
+	ListID   int    `json:"list_id" yaml:"list_id"`
+	Name     string `json:"name" yaml:"name"`
+	NewName  string `json:"new_name,omitempty" yaml:"new_name,omitempty"`
+	Add      int    `json:"add" yaml:"add"`
+	Existing int    `json:"existing" yaml:"existing"`
+	Remove   int    `json:"remove" yaml:"remove"`
+	// Unmanaged counts items in the list but not in the manifest, which
+	// are kept unless --prune is given.
+	Unmanaged int         `json:"unmanaged" yaml:"unmanaged"`
+	Items     []applyItem `json:"items" yaml:"items"`
+
+	toAdd    client.ModifyListRequest
+	toRemove client.ModifyListRequest
+}
+
+// applyPlan is the result of apply and apply --dry-run.
+type applyPlan struct {
+	Lists []*listPlan `json:"lists" yaml:"lists"`
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Converge static lists to the state declared in a manifest.",
+	Long: `Converge static lists to the state declared in a YAML manifest:
+
+  lists:
+    - id: 113124
+      name: Best of 2024        # optional, renames the list
+      movies: [tt0133093, 603]  # IMDb (tt...) or TMDb IDs
+      shows: [tt0903747]
+
+Each list is compared with its current items and the plan is printed. Missing
+items are then added and the list renamed if needed. Items of the list that
+the manifest does not mention are only removed with --prune. Use --dry-run
+to print the plan without changing anything.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("filename")
+		prune, _ := cmd.Flags().GetBool("prune")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if file == "" {
+			return usageErrorf("--filename is required")
+		}
+
+		m, err := readManifest(file)
+		if err != nil {
+			return err
+		}
+
+		plan := &applyPlan{}
+		for _, list := range m.Lists {
+			lp, err := planList(cmd.Context(), list, prune)
+			if err != nil {
+				return fmt.Errorf("list %d: %w", list.ID, err)
+			}
+			plan.Lists = append(plan.Lists, lp)
+		}
+
+		requests := 0
+		for _, lp := range plan.Lists {
+			fmt.Fprintln(os.Stderr, lp.summary(prune))
+			requests += lp.requests()
+		}
+		if err := printData(plan); err != nil {
+			return err
+		}
+		if dryRun {
+			fmt.Fprintln(os.Stderr, "Dry run: nothing was changed.")
+			return nil
+		}
+		if requests == 0 {
+			fmt.Fprintln(os.Stderr, "Nothing to do.")
+			return nil
+		}
+
+		if err := checkQuota(cmd.Context(), requests); err != nil {
+			return err
+		}
+		for _, lp := range plan.Lists {
+			if err := applyList(cmd.Context(), lp); err != nil {
+				return fmt.Errorf("list %d: %w", lp.ListID, err)
+			}
+		}
+		fmt.Fprintln(os.Stderr, "Apply complete.")
+		return nil
+	},
+}
+
+func readManifest(file string) (*manifest, error) {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", file, err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var m manifest
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&m); err != nil && err != io.EOF {
+		return nil, usageErrorf("invalid manifest %s: %v", file, err)
+	}
+	if len(m.Lists) == 0 {
+		return nil, usageErrorf("manifest %s declares no lists", file)
+	}
+	seen := map[int]bool{}
+	for i, list := range m.Lists {
+		if list.ID <= 0 {
+			return nil, usageErrorf("manifest %s: list %d has no id", file, i+1)
+		}
+		if seen[list.ID] {
+			return nil, usageErrorf("manifest %s: list %d is declared twice", file, list.ID)
+		}
+		seen[list.ID] = true
+	}
+	return &m, nil
+}
+
+// planList compares a manifest entry with the current state of its list.
+func planList(ctx context.Context, list manifestList, prune bool) (*listPlan, error) {
+	var desired client.ModifyListRequest
+	var entries []mediaEntry
+	for _, id := range list.Movies {
+		entries = append(entries, mediaEntry{id: id, mediaType: "movie"})
+	}
+	for _, id := range list.Shows {
+		entries = append(entries, mediaEntry{id: id, mediaType: "show"})
+	}
+	if err := addMediaEntries(&desired, entries, "movie"); err != nil {
+		return nil, err
+	}
+
+	lists, err := apiClient.GetListByIDContext(ctx, list.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(lists) == 0 {
+		return nil, fmt.Errorf("list not found")
+	}
+	current, err := fetchAllListItems(ctx, list.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	lp := &listPlan{ListID: list.ID, Name: lists[0].Name, Items: []applyItem{}}
+	if list.Name != "" && list.Name != lists[0].Name {
+		lp.NewName = list.Name
+	}
+
+	// Items to add, and which current items the manifest accounts for
+	index := newItemIndex(current)
+	kept := map[string]bool{}
+	diff := func(mediaType string, refs []map[string]interface{}) {
+		for _, ref := range refs {
+			item, id, found := index.lookup(mediaType, ref)
+			p := planItem{MediaType: mediaType, ID: id, Title: item.Title}
+			if found {
+				p.Change = changeExists
+				lp.Existing++
+				kept[itemKey(item)] = true
+			} else {
+				p.Change = changeAdd
+				lp.Add++
+				if mediaType == "show" {
+					lp.toAdd.Shows = append(lp.toAdd.Shows, ref)
+				} else {
+					lp.toAdd.Movies = append(lp.toAdd.Movies, ref)
+				}
+			}
+			lp.Items = append(lp.Items, applyItem{ListID: list.ID, planItem: p})
+		}
+	}
+	diff("movie", desired.Movies)
+	diff("show", desired.Shows)
+
+	for _, item := range current {
+		if kept[itemKey(item)] {
+			continue
+		}
+		if !prune {
+			lp.Unmanaged++
+			continue
+		}
+		ref, id, mediaType := currentRef(item)
+		lp.Remove++
+		if mediaType == "show" {
+			lp.toRemove.Shows = append(lp.toRemove.Shows, ref)
+		} else {
+			lp.toRemove.Movies = append(lp.toRemove.Movies, ref)
+		}
+		lp.Items = append(lp.Items, applyItem{ListID: list.ID, planItem: planItem{
+			Change:    changeRemove,
+			MediaType: mediaType,
+			ID:        id,
+			Title:     item.Title,
+		}})
+	}
+	return lp, nil
+}
+
+func itemKey(item client.ListItem) string {
+	return item.MediaType + ":" + strconv.Itoa(item.ID) + ":" + item.ImdbID
+}
+
+// currentRef builds the request reference that removes an item of the list.
+func currentRef(item client.ListItem) (ref map[string]interface{}, id, mediaType string) {
+	mediaType = item.MediaType
+	if mediaType == "" {
+		mediaType = "movie"
+	}
+	if item.ID > 0 {
+		return map[string]interface{}{"tmdb": item.ID}, strconv.Itoa(item.ID), mediaType
+	}
+	return map[string]interface{}{"imdb": item.ImdbID}, item.ImdbID, mediaType
+}
+
+// requests is the number of API calls needed to apply the plan.
+func (lp *listPlan) requests() int {
+	n := 0
+	if lp.NewName != "" {
+		n++
+	}
+	for _, r := range []client.ModifyListRequest{lp.toAdd, lp.toRemove} {
+		n += (r.Count() + client.DefaultModifyBatchSize - 1) / client.DefaultModifyBatchSize
+	}
+	return n
+}
+
+func (lp *listPlan) summary(prune bool) string {
+	s := fmt.Sprintf("List %d (%s): %d to add, %d already present", lp.ListID, lp.Name, lp.Add, lp.Existing)
+	if prune {
+		s += fmt.Sprintf(", %d to remove", lp.Remove)
+	} else if lp.Unmanaged > 0 {
+		s += fmt.Sprintf(", %d not in the manifest (kept, use --prune to remove)", lp.Unmanaged)
+	}
+	if lp.NewName != "" {
+		s += fmt.Sprintf(", rename to %q", lp.NewName)
+	}
+	return s
+}
+
+// applyList renames the list, then adds and removes items as planned.
+func applyList(ctx context.Context, lp *listPlan) error {
+	if lp.NewName != "" {
+		if _, err := apiClient.UpdateListNameByIDContext(ctx, lp.ListID, lp.NewName); err != nil {
+			return fmt.Errorf("rename failed: %w", err)
+		}
+		logVerbose("List %d renamed to %q", lp.ListID, lp.NewName)
+	}
+	for _, step := range []struct {
+		action string
+		items  client.ModifyListRequest
+	}{{"add", lp.toAdd}, {"remove", lp.toRemove}} {
+		if step.items.Count() == 0 {
+			continue
+		}
+		if _, err := apiClient.ModifyListItemsBatchedContext(ctx, lp.ListID, step.action, step.items, client.BatchOptions{}); err != nil {
+			return fmt.Errorf("%s failed: %w", step.action, err)
+		}
+		logVerbose("List %d: %s of %d items done", lp.ListID, step.action, step.items.Count())
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+	applyCmd.Flags().StringP("filename", "f", "", "Manifest declaring the lists ('-' for stdin)")
+	applyCmd.Flags().Bool("prune", false, "Remove items of the lists that the manifest does not mention")
+	applyCmd.Flags().Bool("dry-run", false, "Print the plan without changing anything")
+}
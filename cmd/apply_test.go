@@ -0,0 +1,63 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+const testManifest = `lists:
+  - id: 1
+    name: Classics
+    movies: [tt0133093, 604]
+`
+
+func TestApply(t *testing.T) {
+	srv := newServer(t)
+	file := writeFile(t, "lists.yaml", testManifest)
+
+	var plan applyPlan
+	r := mustRun(t, "apply", "-f", file, "--dry-run")
+	decode(t, r, &plan)
+	if len(plan.Lists) != 1 || plan.Lists[0].NewName != "Classics" || plan.Lists[0].Add != 1 || plan.Lists[0].Existing != 1 || plan.Lists[0].Unmanaged != 1 {
+		t.Errorf("dry run plan = %+v", plan.Lists[0])
+	}
+	if items := srv.ListItems(1); len(items.Movies) != 1 {
+		t.Errorf("dry run changed the list: %+v", items)
+	}
+
+	r = execute(t, testManifest, "apply", "-f", "-", "--prune")
+	if r.code != exitOK || !strings.Contains(r.stderr, "Apply complete.") {
+		t.Fatalf("exit code = %d, stderr:\n%s", r.code, r.stderr)
+	}
+	items := srv.ListItems(1)
+	if len(items.Movies) != 2 || len(items.Shows) != 0 {
+		t.Errorf("list after apply --prune = %+v", items)
+	}
+
+	r = mustRun(t, "apply", "-f", file)
+	if !strings.Contains(r.stderr, "Nothing to do.") {
+		t.Errorf("second apply stderr:\n%s", r.stderr)
+	}
+
+	tests := []struct {
+		name     string
+		manifest string
+		code     int
+	}{
+		{"unknown field", "lists:\n  - id: 1\n    items: [603]\n", exitUsage},
+		{"missing id", "lists:\n  - movies: [603]\n", exitUsage},
+		{"unknown list", "lists:\n  - id: 999\n    movies: [603]\n", exitNotFound},
+		{"dynamic list", "lists:\n  - id: 2\n    movies: [603]\n", exitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if r := execute(t, tt.manifest, "apply", "-f", "-"); r.code != tt.code {
+				t.Errorf("exit code = %d, want %d; stderr:\n%s", r.code, tt.code, r.stderr)
+			}
+		})
+	}
+	if r := execute(t, "", "apply"); r.code != exitUsage {
+		t.Errorf("apply without -f: exit code = %d", r.code)
+	}
+}
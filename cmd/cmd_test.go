@@ -0,0 +1,390 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/luckylittle/mdblist-cli/internal/mdblisttest"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestMain(m *testing.M) {
+	markUsageErrors(rootCmd)
+	os.Exit(m.Run())
+}
+
+// result is what a command line printed and its exit code.
+type result struct {
+	stdout string
+	stderr string
+	code   int
+}
+
+// newServer starts a fake MDBList API seeded with the sample fixtures and
+// points the CLI at it, with configuration and snapshots in temporary
+// directories.
+func newServer(t *testing.T) *mdblisttest.Server {
+	t.Helper()
+	srv := mdblisttest.NewServer("", mdblisttest.SampleFixtures())
+	t.Cleanup(srv.Close)
+	t.Setenv("MDBLIST_API_KEY", srv.APIKey)
+	t.Setenv("MDBLIST_API_URL", srv.URL)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	for _, env := range []string{"MDBLIST_PROFILE", "MDBLIST_CONFIG", "MDBLIST_SNAPSHOT_DIR"} {
+		t.Setenv(env, "")
+	}
+	return srv
+}
+
+// execute runs a command line in-process, feeding stdin to it, and captures
+// its output.
+func execute(t *testing.T, stdin string, args ...string) result {
+	t.Helper()
+	return executeContext(t, context.Background(), stdin, args...)
+}
+
+// executeContext is execute with the context Execute would get from its
+// signal handler.
+func executeContext(t *testing.T, ctx context.Context, stdin string, args ...string) result {
+	t.Helper()
+	resetCommands(rootCmd)
+	// profiles of earlier tests must not leak through viper defaults
+	viper.SetDefault("api_key", "")
+
+	dir := t.TempDir()
+	files := map[string]*os.File{}
+	for _, name := range []string{"stdin", "stdout", "stderr"} {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		files[name] = f
+	}
+	if _, err := io.WriteString(files["stdin"], stdin); err != nil {
+		t.Fatal(err)
+	}
+	files["stdin"].Seek(0, io.SeekStart)
+
+	oldIn, oldOut, oldErr := os.Stdin, os.Stdout, os.Stderr
+	os.Stdin, os.Stdout, os.Stderr = files["stdin"], files["stdout"], files["stderr"]
+	rootCmd.SetArgs(args)
+	code := run(ctx)
+	os.Stdin, os.Stdout, os.Stderr = oldIn, oldOut, oldErr
+
+	read := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	return result{stdout: read("stdout"), stderr: read("stderr"), code: code}
+}
+
+// resetCommands puts every flag back to its default, including those a
+// profile changed behind pflag's back, and drops the contexts cobra keeps
+// from the previous execution.
+func resetCommands(c *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if !f.Changed && f.Value.String() == f.DefValue {
+			return
+		}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			sv.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	c.Flags().VisitAll(reset)
+	c.PersistentFlags().VisitAll(reset)
+	c.SetContext(nil)
+	for _, sub := range c.Commands() {
+		resetCommands(sub)
+	}
+}
+
+// mustRun executes a command line that is expected to succeed.
+func mustRun(t *testing.T, args ...string) result {
+	t.Helper()
+	r := execute(t, "", args...)
+	if r.code != exitOK {
+		t.Fatalf("%s: exit code %d, stderr:\n%s", strings.Join(args, " "), r.code, r.stderr)
+	}
+	return r
+}
+
+// decode unmarshals the JSON printed by a command.
+func decode(t *testing.T, r result, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(r.stdout), v); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, r.stdout)
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(*mdblisttest.Server)
+		args   []string
+		code   int
+		stderr string
+	}{
+		{name: "ok", args: []string{"get", "my-limits"}, code: exitOK},
+		{name: "unknown command", args: []string{"bogus"}, code: exitUsage, stderr: "unknown command"},
+		{name: "misspelt command", args: []string{"upate"}, code: exitUsage, stderr: "Did you mean this?\n\tupdate"},
+		{name: "root without command", args: []string{}, code: exitOK},
+		{name: "bad flag", args: []string{"get", "my-limits", "--bogus"}, code: exitUsage, stderr: "unknown flag"},
+		{name: "missing required flag", args: []string{"update", "list-items", "--id", "42"}, code: exitUsage, stderr: `required flag(s) "action" not set`},
+		{name: "bad args", args: []string{"get", "media-info", "imdb"}, code: exitUsage, stderr: "accepts 3 arg(s)"},
+		{
+			name:  "unauthorized",
+			setup: func(*mdblisttest.Server) { os.Setenv("MDBLIST_API_KEY", "wrong") },
+			args:  []string{"get", "my-limits"}, code: exitAuth, stderr: "Invalid API key",
+		},
+		{name: "unparsable api url", args: []string{"get", "my-limits", "--api-url", "http://[::1"}, code: exitUsage, stderr: "invalid base URL"},
+		{name: "api url without scheme", args: []string{"get", "my-limits", "--api-url", "localhost:8080"}, code: exitUsage, stderr: "invalid base URL"},
+		{name: "not found", args: []string{"get", "list", "--id", "999"}, code: exitNotFound, stderr: "List not found"},
+		{
+			name:  "rate limited",
+			setup: func(s *mdblisttest.Server) { s.FailNext(1, http.StatusTooManyRequests, "Too many requests") },
+			args:  []string{"get", "my-limits", "--retries", "0"}, code: exitRateLimited,
+		},
+		{
+			name:  "quota exceeded",
+			setup: func(s *mdblisttest.Server) { s.FailNext(1, http.StatusTooManyRequests, "API Limit Reached!") },
+			args:  []string{"get", "my-lists", "--retries", "0"}, code: exitRateLimited, stderr: "API Limit Reached!",
+		},
+		{
+			name:  "server error",
+			setup: func(s *mdblisttest.Server) { s.FailNext(1, http.StatusInternalServerError, "Internal error") },
+			args:  []string{"get", "top-lists", "--retries", "0"}, code: exitServer, stderr: "status 500",
+		},
+		{
+			name:  "server error retried",
+			setup: func(s *mdblisttest.Server) { s.FailNext(1, http.StatusBadGateway, "Bad gateway") },
+			args:  []string{"get", "top-lists", "--retries", "1", "--retry-max-wait", "1ms"}, code: exitOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newServer(t)
+			if tt.setup != nil {
+				tt.setup(srv)
+			}
+			r := execute(t, "", tt.args...)
+			if r.code != tt.code {
+				t.Errorf("exit code = %d, want %d; stderr:\n%s", r.code, tt.code, r.stderr)
+			}
+			if !strings.Contains(r.stderr, tt.stderr) {
+				t.Errorf("stderr does not mention %q:\n%s", tt.stderr, r.stderr)
+			}
+		})
+	}
+}
+
+func TestUsageErrorsBeforeClientSetup(t *testing.T) {
+	newServer(t)
+	t.Setenv("MDBLIST_API_KEY", "")
+	r := execute(t, "", "update", "list-items", "--id", "42")
+	if r.code != exitUsage || !strings.Contains(r.stderr, `required flag(s) "action" not set`) {
+		t.Errorf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+}
+
+func TestMarkUsageErrors(t *testing.T) {
+	newCmd := func() *cobra.Command {
+		c := &cobra.Command{
+			Use:  "test",
+			Args: cobra.MaximumNArgs(1),
+			RunE: func(*cobra.Command, []string) error { return nil },
+		}
+		c.Flags().String("name", "", "")
+		c.Flags().Int("id", 0, "")
+		c.Flags().Bool("a", false, "")
+		c.Flags().Bool("b", false, "")
+		c.MarkFlagRequired("name")
+		c.MarkFlagsMutuallyExclusive("a", "b")
+		c.MarkFlagsOneRequired("id", "a")
+		c.SilenceErrors, c.SilenceUsage = true, true
+		markUsageErrors(c)
+		return c
+	}
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"--name", "x", "--id", "1"}, ""},
+		{[]string{"--name", "x", "--id", "1", "one", "two"}, "accepts at most 1 arg(s)"},
+		{[]string{"--id", "1"}, `required flag(s) "name" not set`},
+		{[]string{"--name", "x", "--a", "--b"}, "if any flags in the group [a b] are set none of the others can be"},
+		{[]string{"--name", "x"}, "at least one of the flags in the group [id a] is required"},
+	}
+	for _, tt := range tests {
+		c := newCmd()
+		c.SetArgs(tt.args)
+		err := c.Execute()
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%v: %v", tt.args, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: error = %v, want %q", tt.args, err, tt.err)
+		}
+		if code := exitCode(err); code != exitUsage {
+			t.Errorf("%v: exit code = %d, want %d", tt.args, code, exitUsage)
+		}
+	}
+}
+
+func TestMissingAPIKey(t *testing.T) {
+	newServer(t)
+	t.Setenv("MDBLIST_API_KEY", "")
+	r := execute(t, "", "get", "my-limits")
+	if r.code != exitAuth || !strings.Contains(r.stderr, "export MDBLIST_API_KEY") {
+		t.Errorf("exit code %d, stderr:\n%s", r.code, r.stderr)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	srv := newServer(t)
+	srv.FailNextRetryAfter(1, http.StatusTooManyRequests, "Slow down", "9")
+	r := execute(t, "", "get", "my-limits", "--retries", "0", "-o", "json")
+	if r.code != exitRateLimited {
+		t.Fatalf("exit code = %d", r.code)
+	}
+	var out jsonError
+	if err := json.Unmarshal([]byte(r.stderr), &out); err != nil {
+		t.Fatalf("stderr is not a JSON error: %v\n%s", err, r.stderr)
+	}
+	if out.Error.Kind != kindRateLimited || out.Error.Status != 429 || out.Error.APIMessage != "Slow down" || out.Error.RetryAfter != 9 {
+		t.Errorf("JSON error = %+v", out.Error)
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	srv := newServer(t)
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"-o", "json"}, []string{`"title": "The Matrix"`}},
+		{[]string{"-o", "yaml"}, []string{"title: The Matrix", "imdb_id: tt0133093"}},
+		{[]string{"-o", "ndjson"}, []string{`{"id":603,`, `"title":"Dark"`}},
+		{[]string{"-o", "table"}, []string{"RANK", "The Matrix", "Dark"}},
+		{[]string{"-o", "table", "--columns", "title,imdb_id", "--no-headers"}, []string{"The Matrix   tt0133093"}},
+		{[]string{"-o", "csv", "--columns", "title,release_year"}, []string{"title,release_year\r\nThe Matrix,1999\r\nDark,2017\r\n"}},
+		{[]string{"-o", "tsv", "--columns", "title"}, []string{"title\nThe Matrix\nDark\n"}},
+		{[]string{"-o", "go-template={{range .movies}}{{.title}}{{end}}"}, []string{"The Matrix"}},
+		{[]string{"-o", "jsonpath={.shows[*].imdb_id}"}, []string{"tt5753856"}},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			args := append([]string{"get", "list-items", "--id", "1"}, tt.args...)
+			r := mustRun(t, args...)
+			for _, want := range tt.want {
+				if !strings.Contains(r.stdout, want) {
+					t.Errorf("output does not contain %q:\n%s", want, r.stdout)
+				}
+			}
+		})
+	}
+
+	// the format is checked before any request is sent
+	for _, format := range []string{"bogus", "jsonpath", "xml=x"} {
+		before := srv.Requests()
+		r := execute(t, "", "update", "list-name", "Renamed", "--id", "1", "-o", format)
+		if r.code != exitUsage {
+			t.Errorf("-o %s: exit code = %d, want %d", format, r.code, exitUsage)
+		}
+		if n := srv.Requests() - before; n != 0 {
+			t.Errorf("-o %s: sent %d requests before failing", format, n)
+		}
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	newServer(t)
+	dir := t.TempDir()
+	recorded := mustRun(t, "get", "list", "--id", "1", "--record", dir)
+
+	// replay works without the server and without a key
+	t.Setenv("MDBLIST_API_URL", "http://127.0.0.1:1")
+	t.Setenv("MDBLIST_API_KEY", "")
+	replayed := mustRun(t, "get", "list", "--id", "1", "--replay", dir)
+	if replayed.stdout != recorded.stdout {
+		t.Errorf("replayed output differs:\n%s\nwant:\n%s", replayed.stdout, recorded.stdout)
+	}
+	if r := execute(t, "", "get", "list", "--id", "1", "--record", dir, "--replay", dir); r.code != exitUsage {
+		t.Errorf("--record with --replay: exit code = %d", r.code)
+	}
+}
+
+// blockingServer accepts requests and holds them until they are abandoned,
+// announcing each on the returned channel.
+func blockingServer(t *testing.T) (string, <-chan struct{}) {
+	t.Helper()
+	started := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, started
+}
+
+func TestInterrupt(t *testing.T) {
+	newServer(t)
+	url, started := blockingServer(t)
+	t.Setenv("MDBLIST_API_URL", url)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-started
+		cancel()
+	}()
+	r := executeContext(t, ctx, "", "get", "my-limits", "-o", "json")
+	if r.code != exitInterrupted {
+		t.Fatalf("exit code = %d, want %d; stderr:\n%s", r.code, exitInterrupted, r.stderr)
+	}
+	var out jsonError
+	if err := json.Unmarshal([]byte(r.stderr), &out); err != nil || out.Error.Kind != kindInterrupted {
+		t.Errorf("JSON error = %+v (%v)", out.Error, err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	newServer(t)
+	url, _ := blockingServer(t)
+	t.Setenv("MDBLIST_API_URL", url)
+	r := execute(t, "", "get", "my-limits", "--timeout", "50ms", "--retries", "0")
+	if r.code != exitNetwork {
+		t.Errorf("exit code = %d, want %d; stderr:\n%s", r.code, exitNetwork, r.stderr)
+	}
+}
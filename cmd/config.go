@@ -0,0 +1,125 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage profiles in the configuration file.",
+	Long: `Manage named profiles in ~/.config/mdblist-cli/config.yaml.
+
+A profile holds an API key, a base URL, a default output format and a default
+list ID (used by list commands when no list is given). Flags and environment
+variables take precedence over the active profile, which is chosen by
+--profile, then MDBLIST_PROFILE, then 'config use-profile'.`,
+	// Managing the configuration needs no API client
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return loadConfig(cmd)
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a value in the active profile, creating the profile if needed.",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// an empty value clears the default
+		if args[0] == "output" && args[1] != "" {
+			if err := checkOutputFormat(args[1]); err != nil {
+				return err
+			}
+		}
+		if err := cfg.EnsureProfile(profileName).Set(args[0], args[1]); err != nil {
+			return &codedError{kind: kindUsage, err: err}
+		}
+		if err := cfg.Save(configPath); err != nil {
+			return err
+		}
+		logVerbose("Set %s in profile %q", args[0], profileName)
+		return nil
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print a value of the active profile.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		value, err := profile.Get(args[0])
+		if err != nil {
+			return &codedError{kind: kindUsage, err: err}
+		}
+		fmt.Println(value)
+		return nil
+	},
+}
+
+// profileEntry is a row of 'config list'. API keys are masked.
+type profileEntry struct {
+	Name    string `json:"name" yaml:"name"`
+	Current bool   `json:"current" yaml:"current"`
+	APIKey  string `json:"api_key" yaml:"api_key"`
+	APIURL  string `json:"api_url" yaml:"api_url"`
+	Output  string `json:"output" yaml:"output"`
+	ListID  int    `json:"list_id" yaml:"list_id"`
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the profiles, marking the active one.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries := make([]profileEntry, 0, len(cfg.Profiles))
+		for _, name := range cfg.Names() {
+			p, _ := cfg.Profile(name)
+			entries = append(entries, profileEntry{
+				Name:    name,
+				Current: name == profileName,
+				APIKey:  maskKey(p.APIKey),
+				APIURL:  p.APIURL,
+				Output:  p.Output,
+				ListID:  p.ListID,
+			})
+		}
+		return printData(entries)
+	},
+}
+
+var configUseProfileCmd = &cobra.Command{
+	Use:   "use-profile <name>",
+	Short: "Make a profile the default one.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, ok := cfg.Profile(args[0]); !ok {
+			return usageErrorf("profile %q does not exist, create it with 'config set --profile %s api_key ...'", args[0], args[0])
+		}
+		cfg.CurrentProfile = args[0]
+		if err := cfg.Save(configPath); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Switched to profile %q.\n", args[0])
+		return nil
+	},
+}
+
+// maskKey keeps the last four characters of an API key.
+func maskKey(key string) string {
+	if len(key) <= 4 {
+		return strings.Repeat("*", len(key))
+	}
+	return strings.Repeat("*", len(key)-4) + key[len(key)-4:]
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configUseProfileCmd)
+}
@@ -0,0 +1,75 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigProfiles(t *testing.T) {
+	newServer(t)
+
+	mustRun(t, "config", "set", "list_id", "1")
+	mustRun(t, "config", "set", "output", "yaml")
+	mustRun(t, "config", "set", "--profile", "work", "api_key", "work-secret-key")
+	if r := mustRun(t, "config", "get", "list_id"); r.stdout != "1\n" {
+		t.Errorf("config get list_id = %q", r.stdout)
+	}
+
+	var entries []profileEntry
+	decode(t, mustRun(t, "config", "list", "-o", "json"), &entries)
+	if len(entries) != 2 || !entries[0].Current || entries[1].Name != "work" || entries[1].APIKey != "***********-key" {
+		t.Errorf("config list = %+v", entries)
+	}
+
+	// the profile supplies the list and the output format
+	r := mustRun(t, "get", "list-items")
+	if !strings.Contains(r.stdout, "title: The Matrix") {
+		t.Errorf("get list-items with the profile defaults =\n%s", r.stdout)
+	}
+	if r := mustRun(t, "get", "list-items", "-o", "json"); !strings.Contains(r.stdout, `"title": "The Matrix"`) {
+		t.Errorf("-o json does not override the profile:\n%s", r.stdout)
+	}
+
+	r = mustRun(t, "config", "use-profile", "work")
+	if !strings.Contains(r.stderr, `Switched to profile "work".`) {
+		t.Errorf("use-profile stderr = %q", r.stderr)
+	}
+	if r := mustRun(t, "config", "get", "list_id"); r.stdout != "\n" {
+		t.Errorf("config get list_id in profile work = %q", r.stdout)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"unknown key", []string{"config", "set", "colour", "blue"}, exitUsage},
+		{"invalid list_id", []string{"config", "set", "list_id", "abc"}, exitUsage},
+		{"invalid output", []string{"config", "set", "output", "xml"}, exitUsage},
+		{"output without value", []string{"config", "set", "output", "jsonpath"}, exitUsage},
+		{"get unknown key", []string{"config", "get", "colour"}, exitUsage},
+		{"unknown profile", []string{"config", "use-profile", "nope"}, exitUsage},
+		{"unknown --profile", []string{"get", "my-limits", "--profile", "nope"}, exitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if r := execute(t, "", tt.args...); r.code != tt.code {
+				t.Errorf("exit code = %d, want %d; stderr:\n%s", r.code, tt.code, r.stderr)
+			}
+		})
+	}
+
+	if r := mustRun(t, "config", "get", "output"); r.stdout != "\n" {
+		t.Errorf("an invalid output format was saved: %q", r.stdout)
+	}
+
+	mustRun(t, "config", "set", "output", "jsonpath={.movies[*].title}")
+	if r := mustRun(t, "get", "list-items", "--id", "1"); r.stdout != "The Matrix" {
+		t.Errorf("get list-items with the jsonpath profile = %q", r.stdout)
+	}
+	mustRun(t, "config", "set", "output", "")
+	if r := mustRun(t, "config", "get", "output"); r.stdout != "\n" {
+		t.Errorf("config get output after clearing it = %q", r.stdout)
+	}
+}
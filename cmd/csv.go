@@ -0,0 +1,47 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+)
+
+func printDelimited(data interface{}, comma rune) error {
+	if err := writeDelimited(os.Stdout, data, comma); err != nil {
+		return fmt.Errorf("formatting delimited output: %w", err)
+	}
+	return nil
+}
+
+// writeDelimited writes one record per row with nested fields flattened
+// into dotted column names. Fields are quoted as described in RFC 4180, and
+// CSV records also end in CRLF as it asks; TSV records end in LF.
+func writeDelimited(w io.Writer, data interface{}, comma rune) error {
+	rows := rowsOf(data)
+	cols := exportColumns(rows)
+	if len(cols) == 0 {
+		return nil
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	cw.UseCRLF = comma == ','
+	if !noHeaders {
+		if err := cw.Write(cols); err != nil {
+			return err
+		}
+	}
+	for _, row := range rows {
+		cells, err := rowCells(row, cols)
+		if err != nil {
+			return err
+		}
+		if err := cw.Write(cells); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
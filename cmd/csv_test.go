@@ -0,0 +1,73 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+)
+
+func TestWriteDelimited(t *testing.T) {
+	items := &client.ListItems{
+		Movies: []client.ListItem{
+			{Rank: 1, Title: "Crouching Tiger, Hidden Dragon", ReleaseYear: 2000},
+			{Rank: 2, Title: `The "Burbs"`, ReleaseYear: 1989},
+		},
+		Shows: []client.ListItem{
+			{Rank: 3, Title: "Line one\nline two", ReleaseYear: 2017},
+		},
+	}
+	tests := []struct {
+		name      string
+		comma     rune
+		columns   []string
+		noHeaders bool
+		want      string
+	}{
+		{
+			name: "csv", comma: ',', columns: []string{"rank", "title"},
+			want: "rank,title\r\n" +
+				"1,\"Crouching Tiger, Hidden Dragon\"\r\n" +
+				"2,\"The \"\"Burbs\"\"\"\r\n" +
+				"3,\"Line one\r\nline two\"\r\n",
+		},
+		{
+			name: "tsv", comma: '\t', columns: []string{"rank", "title"},
+			want: "rank\ttitle\n" +
+				"1\tCrouching Tiger, Hidden Dragon\n" +
+				"2\t\"The \"\"Burbs\"\"\"\n" +
+				"3\t\"Line one\nline two\"\n",
+		},
+		{
+			name: "no headers", comma: ',', columns: []string{"title", "release_year"}, noHeaders: true,
+			want: "\"Crouching Tiger, Hidden Dragon\",2000\r\n" +
+				"\"The \"\"Burbs\"\"\",1989\r\n" +
+				"\"Line one\r\nline two\",2017\r\n",
+		},
+		{
+			name: "columns in the given order", comma: '\t', columns: []string{"release_year", "rank"},
+			want: "release_year\trank\n2000\t1\n1989\t2\n2017\t3\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, noHeaders = tt.columns, tt.noHeaders
+			t.Cleanup(func() { columns, noHeaders = nil, false })
+
+			var out strings.Builder
+			if err := writeDelimited(&out, items, tt.comma); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got:\n%q\nwant:\n%q", out.String(), tt.want)
+			}
+		})
+	}
+
+	columns = []string{"title", "bogus"}
+	t.Cleanup(func() { columns = nil })
+	if err := writeDelimited(&strings.Builder{}, items, ','); err == nil || !strings.Contains(err.Error(), `unknown column "bogus"`) {
+		t.Errorf("unknown column: err = %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes returned by the CLI.
+const (
+	exitOK          = 0
+	exitError       = 1   // any other failure
+	exitUsage       = 2   // invalid flags or arguments
+	exitAuth        = 3   // 401/403: missing or invalid API key
+	exitNotFound    = 4   // 404: list, user or media not found
+	exitRateLimited = 5   // 429 or the daily quota is used up
+	exitServer      = 6   // 5xx from MDBList
+	exitNetwork     = 7   // connection failures and timeouts
+	exitInterrupted = 130 // Ctrl-C, as shells report SIGINT
+)
+
+// Error kinds reported by -o json error output.
+const (
+	kindError         = "error"
+	kindUsage         = "usage"
+	kindUnauthorized  = "unauthorized"
+	kindNotFound      = "not_found"
+	kindRateLimited   = "rate_limited"
+	kindQuotaExceeded = "quota_exceeded"
+	kindServer        = "server_error"
+	kindNetwork       = "network_error"
+	kindInterrupted   = "interrupted"
+)
+
+var exitCodes = map[string]int{
+	kindError:         exitError,
+	kindUsage:         exitUsage,
+	kindUnauthorized:  exitAuth,
+	kindNotFound:      exitNotFound,
+	kindRateLimited:   exitRateLimited,
+	kindQuotaExceeded: exitRateLimited,
+	kindServer:        exitServer,
+	kindNetwork:       exitNetwork,
+	kindInterrupted:   exitInterrupted,
+}
+
+// codedError attaches a kind to errors that cannot be classified by their
+// cause alone, such as invalid flags.
+type codedError struct {
+	kind string
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &codedError{kind: kindUsage, err: fmt.Errorf(format, args...)}
+}
+
+// errorKind classifies an error returned by a command.
+func errorKind(err error) string {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.kind
+	}
+
+	var apiErr *client.APIError
+	var netErr net.Error
+	var urlErr *url.Error
+	switch {
+	case errors.Is(err, client.ErrInvalidBaseURL):
+		// also a *url.Error, but retrying or checking the network won't help
+		return kindUsage
+	case errors.Is(err, client.ErrQuotaExceeded):
+		return kindQuotaExceeded
+	case errors.Is(err, client.ErrUnauthorized):
+		return kindUnauthorized
+	case errors.Is(err, client.ErrNotFound):
+		return kindNotFound
+	case errors.Is(err, client.ErrRateLimited):
+		return kindRateLimited
+	case errors.As(err, &apiErr):
+		if apiErr.StatusCode >= 500 {
+			return kindServer
+		}
+		return kindError
+	case errors.Is(err, context.Canceled):
+		// only Execute's signal handler cancels the context; timeouts
+		// end with context.DeadlineExceeded
+		return kindInterrupted
+	case errors.As(err, &netErr), errors.As(err, &urlErr),
+		errors.Is(err, context.DeadlineExceeded):
+		return kindNetwork
+	}
+	return kindError
+}
+
+// exitCode maps an error returned by a command to the process exit code.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	return exitCodes[errorKind(err)]
+}
+
+// jsonError is the -o json form of a failed command.
+type jsonError struct {
+	Error struct {
+		Kind       string `json:"kind"`
+		Message    string `json:"message"`
+		ExitCode   int    `json:"exit_code"`
+		Status     int    `json:"status,omitempty"`
+		APIMessage string `json:"api_message,omitempty"`
+		RetryAfter int    `json:"retry_after,omitempty"`
+	} `json:"error"`
+}
+
+func writeJSONError(w io.Writer, err error) error {
+	var out jsonError
+	out.Error.Kind = errorKind(err)
+	out.Error.Message = err.Error()
+	out.Error.ExitCode = exitCodes[out.Error.Kind]
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		out.Error.Status = apiErr.StatusCode
+		out.Error.APIMessage = apiErr.Message
+		out.Error.RetryAfter = int(apiErr.RetryAfter.Seconds())
+	}
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// markUsageErrors makes the Args hook of every command also check required
+// flags and flag groups, which cobra would only check later with errors of
+// no particular kind, and reports all their errors with exitUsage.
+func markUsageErrors(c *cobra.Command) {
+	validate := c.Args
+	c.Args = func(cmd *cobra.Command, args []string) error {
+		if validate != nil {
+			if err := validate(cmd, args); err != nil {
+				return asUsageError(err)
+			}
+		}
+		if err := cmd.ValidateRequiredFlags(); err != nil {
+			return asUsageError(err)
+		}
+		if err := cmd.ValidateFlagGroups(); err != nil {
+			return asUsageError(err)
+		}
+		return nil
+	}
+	for _, sub := range c.Commands() {
+		markUsageErrors(sub)
+	}
+}
+
+// asUsageError marks err as a usage error unless it already has a kind.
+func asUsageError(err error) error {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return err
+	}
+	return &codedError{kind: kindUsage, err: err}
+}
@@ -0,0 +1,310 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+	"github.com/luckylittle/mdblist-cli/internal/snapshot"
+)
+
+// rowsOf returns the records held by a command result: the movies and shows
+// of list-like results, the hits of a search, the elements of a slice, or
+// the value itself.
+func rowsOf(data interface{}) []reflect.Value {
+	switch d := data.(type) {
+	case *client.ListItems:
+		return rowsOf(append(append([]client.ListItem{}, d.Movies...), d.Shows...))
+	case *client.WatchlistItems:
+		return rowsOf(append(append([]client.WatchlistItem{}, d.Movies...), d.Shows...))
+	case *client.SearchResult:
+		return rowsOf(d.Search)
+	case *client.RatingsResponse:
+		return rowsOf(d.Ratings)
+	case *itemsPlan:
+		return rowsOf(d.Items)
+	case *snapshotDiff:
+		return rowsOf(d.Items)
+	case *applyPlan:
+		var items []applyItem
+		for _, lp := range d.Lists {
+			items = append(items, lp.Items...)
+		}
+		return rowsOf(items)
+	}
+
+	v := indirect(reflect.ValueOf(data))
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		rows := make([]reflect.Value, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			rows = append(rows, indirect(v.Index(i)))
+		}
+		return rows
+	}
+	return []reflect.Value{v}
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// jsonName returns the JSON key of a struct field, or "" if it is skipped.
+func jsonName(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return ""
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return f.Name
+}
+
+// fieldValue resolves a dotted path of JSON keys, such as "ids.imdb",
+// looking through embedded structs. Ratings are addressed by source, so
+// "ratings.imdb" is the value of the IMDb rating.
+func fieldValue(v reflect.Value, path string) (reflect.Value, bool) {
+	for _, key := range strings.Split(path, ".") {
+		v = indirect(v)
+		if !v.IsValid() {
+			return reflect.Value{}, true
+		}
+		if ratings, ok := v.Interface().([]client.Rating); ok {
+			v = reflect.Value{}
+			for i := range ratings {
+				if ratings[i].Source == key {
+					v = reflect.ValueOf(ratings[i])
+				}
+			}
+			continue
+		}
+		if v.Kind() == reflect.Map {
+			v = v.MapIndex(reflect.ValueOf(key))
+			if !v.IsValid() {
+				return reflect.Value{}, true
+			}
+			continue
+		}
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+		next, ok := structField(v, key)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		v = next
+	}
+	if v.IsValid() {
+		if rating, ok := v.Interface().(client.Rating); ok {
+			v = reflect.ValueOf(rating.Value)
+		}
+	}
+	return v, true
+}
+
+func structField(v reflect.Value, key string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if fv, ok := structField(v.Field(i), key); ok {
+				return fv, true
+			}
+			continue
+		}
+		if jsonName(f) == key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// leafPaths lists the dotted paths of every scalar field of t, flattening
+// nested and embedded structs.
+func leafPaths(t reflect.Type, prefix string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == reflect.TypeOf(time.Time{}) {
+		return []string{strings.TrimSuffix(prefix, ".")}
+	}
+	var paths []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			paths = append(paths, leafPaths(f.Type, prefix)...)
+			continue
+		}
+		name := jsonName(f)
+		if name == "" {
+			continue
+		}
+		paths = append(paths, leafPaths(f.Type, prefix+name+".")...)
+	}
+	return paths
+}
+
+// formatValue renders a field as a single line of text.
+func formatValue(v reflect.Value) string {
+	v = indirect(v)
+	if !v.IsValid() {
+		return ""
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Slice, reflect.Array:
+		parts := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			e := indirect(v.Index(i))
+			if e.IsValid() && (e.Kind() == reflect.Struct || e.Kind() == reflect.Map) {
+				return compactJSON(v)
+			}
+			parts = append(parts, formatValue(e))
+		}
+		return strings.Join(parts, ",")
+	case reflect.Struct, reflect.Map:
+		return compactJSON(v)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func compactJSON(v reflect.Value) string {
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Sprint(v.Interface())
+	}
+	return string(b)
+}
+
+// selectColumns returns the --columns selection or the default columns for
+// the type of the rows.
+func selectColumns(rows []reflect.Value) []string {
+	if len(columns) > 0 {
+		return columns
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	t := rows[0].Type()
+	if cols, ok := defaultColumns[t]; ok {
+		return cols
+	}
+	if t.Kind() != reflect.Struct {
+		return []string{"value"}
+	}
+	// Fall back to every top-level scalar field
+	var cols []string
+	for _, path := range leafPaths(t, "") {
+		if !strings.Contains(path, ".") {
+			if v, ok := fieldValue(rows[0], path); ok && isScalar(v) {
+				cols = append(cols, path)
+			}
+		}
+	}
+	return cols
+}
+
+func isScalar(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		_, isTime := v.Interface().(time.Time)
+		return isTime
+	}
+	return true
+}
+
+// exportColumns returns the --columns selection or every leaf field of the
+// rows, with ratings expanded into one column per source seen in the data.
+func exportColumns(rows []reflect.Value) []string {
+	if len(columns) > 0 {
+		return columns
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	t := rows[0].Type()
+	if t.Kind() != reflect.Struct {
+		return []string{"value"}
+	}
+
+	var cols []string
+	for _, path := range leafPaths(t, "") {
+		if v, _ := fieldValue(rows[0], path); !v.IsValid() || v.Type() != reflect.TypeOf([]client.Rating{}) {
+			cols = append(cols, path)
+			continue
+		}
+		seen := map[string]bool{}
+		for _, row := range rows {
+			rv, _ := fieldValue(row, path)
+			for _, rating := range rv.Interface().([]client.Rating) {
+				if !seen[rating.Source] {
+					seen[rating.Source] = true
+					cols = append(cols, path+"."+rating.Source)
+				}
+			}
+		}
+	}
+	return cols
+}
+
+var defaultColumns = map[reflect.Type][]string{
+	reflect.TypeOf(client.List{}):          {"id", "name", "mediatype", "items", "likes", "user_name"},
+	reflect.TypeOf(client.ListItem{}):      {"rank", "title", "release_year", "mediatype", "imdb_id"},
+	reflect.TypeOf(client.WatchlistItem{}): {"title", "release_year", "mediatype", "imdb_id", "watchlist_at"},
+	reflect.TypeOf(client.SearchItem{}):    {"title", "year", "type", "score_average", "ids.imdbid", "ids.tmdbid"},
+	reflect.TypeOf(client.MediaInfo{}):     {"title", "year", "type", "runtime", "score_average", "ids.imdb", "ids.tmdb"},
+	reflect.TypeOf(ratedListItem{}):        {"rank", "title", "release_year", "imdb_id", "rating"},
+	reflect.TypeOf(planItem{}):             {"change", "mediatype", "id", "title"},
+	reflect.TypeOf(applyItem{}):            {"list_id", "change", "mediatype", "id", "title"},
+	reflect.TypeOf(snapshotChange{}):       {"change", "mediatype", "title", "release_year", "imdb_id"},
+	reflect.TypeOf(snapshot.Info{}):        {"taken_at", "list_name", "movies", "shows"},
+}
+
+// rowCells renders the selected columns of a row.
+func rowCells(row reflect.Value, cols []string) ([]string, error) {
+	cells := make([]string, len(cols))
+	for i, col := range cols {
+		if row.Kind() != reflect.Struct && row.Kind() != reflect.Map && col == "value" {
+			cells[i] = formatValue(row)
+			continue
+		}
+		v, ok := fieldValue(row, col)
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", col)
+		}
+		cells[i] = formatValue(v)
+	}
+	return cells, nil
+}
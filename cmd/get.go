@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"strconv"
 
+	"github.com/luckylittle/mdblist-cli/internal/client"
 	"github.com/spf13/cobra"
 )
 
@@ -17,39 +18,36 @@ var getCmd = &cobra.Command{
 var getMyLimitsCmd = &cobra.Command{
 	Use:   "my-limits",
 	Short: "Show information about user limits.",
-	Run: func(cmd *cobra.Command, args []string) {
-		limits, err := apiClient.GetMyLimits()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limits, err := apiClient.GetMyLimitsContext(cmd.Context())
 		if err != nil {
-			fmt.Println("Error:", err)
-			return
+			return err
 		}
-		printData(limits)
+		return printData(limits)
 	},
 }
 
 var getMyListsCmd = &cobra.Command{
 	Use:   "my-lists",
 	Short: "Fetches users lists.",
-	Run: func(cmd *cobra.Command, args []string) {
-		lists, err := apiClient.GetMyLists()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		lists, err := apiClient.GetMyListsContext(cmd.Context())
 		if err != nil {
-			fmt.Println("Error:", err)
-			return
+			return err
 		}
-		printData(lists)
+		return printData(lists)
 	},
 }
 
 var getUserListsCmd = &cobra.Command{
 	Use:   "user-lists",
 	Short: "Fetch a user's lists.",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		userID, _ := cmd.Flags().GetInt("id")
 		username, _ := cmd.Flags().GetString("username")
 
 		if userID == 0 && username == "" {
-			fmt.Println("Error: either user's --id or --username is required!")
-			return
+			return usageErrorf("either user's --id or --username is required")
 		}
 
 		var (
@@ -58,30 +56,28 @@ var getUserListsCmd = &cobra.Command{
 		)
 
 		if userID != 0 {
-			lists, err = apiClient.GetUserListsByID(userID)
+			lists, err = apiClient.GetUserListsByIDContext(cmd.Context(), userID)
 		} else {
-			lists, err = apiClient.GetUserListsByName(username)
+			lists, err = apiClient.GetUserListsByNameContext(cmd.Context(), username)
 		}
 
 		if err != nil {
-			fmt.Println("Error:", err)
-			return
+			return err
 		}
-		printData(lists)
+		return printData(lists)
 	},
 }
 
 var getListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "Retrieves details of a list.",
-	Run: func(cmd *cobra.Command, args []string) {
-		listID, _ := cmd.Flags().GetInt("id")
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listID := listIDOrDefault(cmd)
 		username, _ := cmd.Flags().GetString("username")
 		listName, _ := cmd.Flags().GetString("listname")
 
 		if listID == 0 && (username == "" || listName == "") {
-			fmt.Println("Error: either --id or both --username and --listname are required!")
-			return
+			return usageErrorf("either --id or both --username and --listname are required")
 		}
 
 		var (
@@ -90,71 +86,154 @@ var getListCmd = &cobra.Command{
 		)
 
 		if listID != 0 {
-			list, err = apiClient.GetListByID(listID)
+			list, err = apiClient.GetListByIDContext(cmd.Context(), listID)
 		} else {
-			list, err = apiClient.GetListByName(username, listName)
+			list, err = apiClient.GetListByNameContext(cmd.Context(), username, listName)
 		}
 
 		if err != nil {
-			fmt.Println("Error:", err)
-			return
+			return err
 		}
-		printData(list)
+		return printData(list)
 	},
 }
 
 var getListItemsCmd = &cobra.Command{
 	Use:   "list-items",
 	Short: "Fetches items from a specified list.",
-	Run: func(cmd *cobra.Command, args []string) {
-		listID, _ := cmd.Flags().GetInt("id")
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listID := listIDOrDefault(cmd)
 		username, _ := cmd.Flags().GetString("username")
 		listName, _ := cmd.Flags().GetString("listname")
 
 		if listID == 0 && (username == "" || listName == "") {
-			fmt.Println("Error: either --id or both --username and --listname are required!")
-			return
+			return usageErrorf("either --id or both --username and --listname are required")
 		}
 
-		var (
-			items interface{}
-			err   error
-		)
+		params, err := listItemsParams(cmd)
+		if err != nil {
+			return err
+		}
 
-		// TODO: extend this to take CLI flags for pagination, sorting, etc.
-		params := url.Values{}
+		all, _ := cmd.Flags().GetBool("all")
+		if !all && output == "ndjson" {
+			if listID != 0 {
+				err = apiClient.StreamListItemsContext(cmd.Context(), listID, params, emitNDJSON[client.ListItem])
+			} else {
+				err = apiClient.StreamListItemsByNameContext(cmd.Context(), username, listName, params, emitNDJSON[client.ListItem])
+			}
+			return err
+		}
+		if !all {
+			var items interface{}
+			if listID != 0 {
+				items, err = apiClient.GetListItemsContext(cmd.Context(), listID, params)
+			} else {
+				items, err = apiClient.GetListItemsByNameContext(cmd.Context(), username, listName, params)
+			}
+			if err != nil {
+				return err
+			}
+			return printData(items)
+		}
 
+		// Walk every page starting at --offset, stopping after --limit items
+		limit, _ := cmd.Flags().GetInt("limit")
+		pageSize := listItemsPageSize
+		if limit > 0 && limit < pageSize {
+			pageSize = limit
+		}
+		params.Set("limit", strconv.Itoa(pageSize))
+		var it *client.Iterator[client.ListItem]
 		if listID != 0 {
-			items, err = apiClient.GetListItems(listID, params)
+			it = apiClient.ListItemsIter(cmd.Context(), listID, params)
 		} else {
-			items, err = apiClient.GetListItemsByName(username, listName, params)
+			it = apiClient.ListItemsByNameIter(cmd.Context(), username, listName, params)
 		}
-
-		if err != nil {
-			fmt.Println("Error:", err)
-			return
+		items := &client.ListItems{Movies: []client.ListItem{}, Shows: []client.ListItem{}}
+		for n := 0; (limit <= 0 || n < limit) && it.Next(); n++ {
+			if output == "ndjson" {
+				// only the current page is kept in memory
+				if err := emitNDJSON(it.Item()); err != nil {
+					return err
+				}
+				continue
+			}
+			if item := it.Item(); item.MediaType == "show" {
+				items.Shows = append(items.Shows, item)
+			} else {
+				items.Movies = append(items.Movies, item)
+			}
 		}
-		printData(items)
+		if err := it.Err(); err != nil {
+			return err
+		}
+		if output == "ndjson" {
+			return nil
+		}
+		logVerbose("Fetched %d items", len(items.Movies)+len(items.Shows))
+		return printData(items)
 	},
 }
 
+// listItemsPageSize is the page size used to read whole lists, e.g. by
+// list-items --all.
+const listItemsPageSize = 500
+
+// listItemsParams builds the query parameters of list-items from its flags.
+func listItemsParams(cmd *cobra.Command) (url.Values, error) {
+	params := url.Values{}
+
+	limit, _ := cmd.Flags().GetInt("limit")
+	offset, _ := cmd.Flags().GetInt("offset")
+	if limit < 0 || offset < 0 {
+		return nil, usageErrorf("--limit and --offset must not be negative")
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	if offset > 0 {
+		params.Set("offset", strconv.Itoa(offset))
+	}
+
+	if sort, _ := cmd.Flags().GetString("sort"); sort != "" {
+		params.Set("sort", sort)
+	}
+	if order, _ := cmd.Flags().GetString("order"); order != "" {
+		if order != "asc" && order != "desc" {
+			return nil, usageErrorf("--order must be either 'asc' or 'desc'")
+		}
+		params.Set("order", order)
+	}
+	if genre, _ := cmd.Flags().GetString("filter-genre"); genre != "" {
+		params.Set("filter_genre", genre)
+	}
+	if unified, _ := cmd.Flags().GetBool("unified"); unified {
+		params.Set("unified", "true")
+	}
+
+	extra, _ := cmd.Flags().GetStringToString("param")
+	for key, value := range extra {
+		params.Set(key, value)
+	}
+	return params, nil
+}
+
 var getListChangesCmd = &cobra.Command{
 	Use:   "list-changes <list-id>",
 	Short: "Returns Trakt IDs for items changed after the last list update.",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		listID, err := strconv.Atoi(args[0])
 		if err != nil {
-			fmt.Println("Error: invalid list ID provided!")
-			return
+			return usageErrorf("invalid list ID provided")
 		}
 
-		changes, err := apiClient.GetListChanges(listID)
+		changes, err := apiClient.GetListChangesContext(cmd.Context(), listID)
 		if err != nil {
-			fmt.Println("Error:", err)
-			return
+			return err
 		}
-		printData(changes)
+		return printData(changes)
 	},
 }
 
@@ -162,64 +241,233 @@ var getMediaInfoCmd = &cobra.Command{
 	Use:   "media-info <provider> <media-type> <media-id>",
 	Short: "Fetch information about a media item",
 	Args:  cobra.ExactArgs(3),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		provider, mediaType, mediaID := args[0], args[1], args[2]
 
 		// Example of how you could add optional params via flags
 		// For now, it's empty.
 		params := url.Values{}
 
-		info, err := apiClient.GetMediaInfo(provider, mediaType, mediaID, params)
+		info, err := apiClient.GetMediaInfoContext(cmd.Context(), provider, mediaType, mediaID, params)
 		if err != nil {
-			fmt.Println("Error:", err)
-			return
+			return err
+		}
+		return printData(info)
+	},
+}
+
+var getMediaInfoBatchCmd = &cobra.Command{
+	Use:   "media-info-batch <provider> <media-type> [media-id...]",
+	Short: "Fetch information about many media items at once.",
+	Long: `Fetch information about many media items at once.
+
+IDs are read from the arguments, from --from-file (one per line) or from
+standard input when "-" is given. They are sent in chunks of --batch-size
+and the results are merged into a single output.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		provider, mediaType := args[0], args[1]
+		fromFile, _ := cmd.Flags().GetString("from-file")
+		batchSize, _ := cmd.Flags().GetInt("batch-size")
+		appendTo, _ := cmd.Flags().GetStringSlice("append")
+
+		if batchSize < 1 || batchSize > client.MediaInfoBatchLimit {
+			return usageErrorf("--batch-size must be between 1 and %d", client.MediaInfoBatchLimit)
+		}
+
+		ids, err := readIDs(args[2:], fromFile)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return usageErrorf("at least one media ID must be provided")
+		}
+
+		chunks := chunk(ids, batchSize)
+		if err := checkQuota(cmd.Context(), len(chunks)); err != nil {
+			return err
+		}
+
+		infos := []client.MediaInfo{}
+		for i, c := range chunks {
+			logVerbose("Fetching chunk %d/%d (%d IDs)", i+1, len(chunks), len(c))
+			batch, err := apiClient.GetMediaInfoBatchContext(cmd.Context(), provider, mediaType, client.MediaInfoBatchRequest{
+				IDs:              c,
+				AppendToResponse: appendTo,
+			})
+			if err != nil {
+				return fmt.Errorf("chunk %d/%d failed: %w", i+1, len(chunks), err)
+			}
+			infos = append(infos, batch...)
 		}
-		printData(info)
+		return printData(infos)
+	},
+}
+
+// ratedListItem is a list item annotated with a rating from GetRatings.
+type ratedListItem struct {
+	client.ListItem `yaml:",inline"`
+	Rating          *float64 `json:"rating" yaml:"rating"`
+}
+
+var getRatingsCmd = &cobra.Command{
+	Use:   "ratings <media-type> <return-rating> [media-id...]",
+	Short: "Fetch one rating source for many media items at once.",
+	Long: `Fetch one rating source (e.g. imdb, letterboxd, tomatoes) for many media items at once.
+
+IDs of --provider are read from the arguments, from --from-file (one per line)
+or from standard input when "-" is given, and sent in chunks of --batch-size.
+With --list-id the IDs are taken from that list instead and the ratings are
+joined back onto its items.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mediaType, returnRating := args[0], args[1]
+		provider, _ := cmd.Flags().GetString("provider")
+		fromFile, _ := cmd.Flags().GetString("from-file")
+		batchSize, _ := cmd.Flags().GetInt("batch-size")
+		listID, _ := cmd.Flags().GetInt("list-id")
+
+		if mediaType != "movie" && mediaType != "show" {
+			return usageErrorf("media type must be either 'movie' or 'show'")
+		}
+		switch provider {
+		case "tmdb", "trakt", "tvdb", "mal":
+		case "imdb":
+			return usageErrorf("--provider imdb is not supported, ratings take numeric IDs: use tmdb, trakt, tvdb or mal")
+		default:
+			return usageErrorf("--provider must be one of tmdb, trakt, tvdb or mal")
+		}
+		if batchSize < 1 || batchSize > client.RatingsBatchLimit {
+			return usageErrorf("--batch-size must be between 1 and %d", client.RatingsBatchLimit)
+		}
+
+		var (
+			ids   []int
+			items []client.ListItem
+		)
+		if listID != 0 {
+			// list item IDs are TMDb IDs
+			if provider != "tmdb" {
+				return usageErrorf("--list-id can only be combined with --provider tmdb")
+			}
+			if len(args) > 2 || fromFile != "" {
+				return usageErrorf("--list-id takes the IDs from the list: drop the media IDs and --from-file")
+			}
+			listItems, err := fetchAllListItems(cmd.Context(), listID)
+			if err != nil {
+				return err
+			}
+			for _, item := range listItems {
+				itemType := item.MediaType
+				if itemType == "" {
+					itemType = "movie"
+				}
+				if itemType == mediaType {
+					items = append(items, item)
+					ids = append(ids, item.ID)
+				}
+			}
+		} else {
+			raw, err := readIDs(args[2:], fromFile)
+			if err != nil {
+				return err
+			}
+			for _, r := range raw {
+				id, err := strconv.Atoi(r)
+				if err != nil {
+					return usageErrorf("invalid %s ID %q: must be numeric", provider, r)
+				}
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			return usageErrorf("at least one media ID must be provided")
+		}
+
+		chunks := chunk(ids, batchSize)
+		if err := checkQuota(cmd.Context(), len(chunks)); err != nil {
+			return err
+		}
+
+		ratings := &client.RatingsResponse{Ratings: []client.RatingScore{}}
+		for i, c := range chunks {
+			logVerbose("Fetching chunk %d/%d (%d IDs)", i+1, len(chunks), len(c))
+			resp, err := apiClient.GetRatingsContext(cmd.Context(), mediaType, returnRating, client.RatingsRequest{
+				IDs:      c,
+				Provider: provider,
+			})
+			if err != nil {
+				return fmt.Errorf("chunk %d/%d failed: %w", i+1, len(chunks), err)
+			}
+			ratings.ProviderID = resp.ProviderID
+			ratings.ProviderRating = resp.ProviderRating
+			ratings.MediaType = resp.MediaType
+			ratings.Ratings = append(ratings.Ratings, resp.Ratings...)
+		}
+
+		if listID == 0 {
+			return printData(ratings)
+		}
+
+		byID := make(map[int]float64, len(ratings.Ratings))
+		for _, r := range ratings.Ratings {
+			byID[r.ID] = r.Rating
+		}
+		rated := make([]ratedListItem, 0, len(items))
+		for _, item := range items {
+			ri := ratedListItem{ListItem: item}
+			if rating, ok := byID[item.ID]; ok {
+				ri.Rating = &rating
+			}
+			rated = append(rated, ri)
+		}
+		return printData(rated)
 	},
 }
 
 var getTopListsCmd = &cobra.Command{
 	Use:   "top-lists",
 	Short: "Outputs the top lists sorted by Trakt likes.",
-	Run: func(cmd *cobra.Command, args []string) {
-		lists, err := apiClient.GetTopLists()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		lists, err := apiClient.GetTopListsContext(cmd.Context())
 		if err != nil {
-			fmt.Println("Error:", err)
-			return
+			return err
 		}
-		printData(lists)
+		return printData(lists)
 	},
 }
 
 var getLastActivitiesCmd = &cobra.Command{
 	Use:   "last-activities",
 	Short: "Fetch the last activity timestamps for sync.",
-	Run: func(cmd *cobra.Command, args []string) {
-		activities, err := apiClient.GetLastActivities()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		activities, err := apiClient.GetLastActivitiesContext(cmd.Context())
 		if err != nil {
-			fmt.Println("Error:", err)
-			return
+			return err
 		}
-		printData(activities)
+		return printData(activities)
 	},
 }
 
 var getWatchlistItemsCmd = &cobra.Command{
 	Use:   "watchlist-items",
 	Short: "Fetches watchlist items, they are sorted by date added.",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		params := url.Values{}
 		sort, _ := cmd.Flags().GetString("sort")
 		if sort != "" {
 			params.Set("sort", sort)
 		}
 
-		items, err := apiClient.GetWatchlistItems(params)
+		if output == "ndjson" {
+			return apiClient.StreamWatchlistItemsContext(cmd.Context(), params, emitNDJSON[client.WatchlistItem])
+		}
+
+		items, err := apiClient.GetWatchlistItemsContext(cmd.Context(), params)
 		if err != nil {
-			fmt.Println("Error:", err)
-			return
+			return err
 		}
-		printData(items)
+		return printData(items)
 	},
 }
 
@@ -232,6 +480,8 @@ func init() {
 	getCmd.AddCommand(getListItemsCmd)
 	getCmd.AddCommand(getListChangesCmd)
 	getCmd.AddCommand(getMediaInfoCmd)
+	getCmd.AddCommand(getMediaInfoBatchCmd)
+	getCmd.AddCommand(getRatingsCmd)
 	getCmd.AddCommand(getTopListsCmd)
 	getCmd.AddCommand(getLastActivitiesCmd)
 	getCmd.AddCommand(getWatchlistItemsCmd)
@@ -246,6 +496,23 @@ func init() {
 	getListItemsCmd.Flags().Int("id", 0, "List ID")
 	getListItemsCmd.Flags().String("username", "", "Username of the list owner")
 	getListItemsCmd.Flags().String("listname", "", "Name/slug of the list")
+	getListItemsCmd.Flags().Int("limit", 0, "Maximum number of items to return (with --all, in total across pages)")
+	getListItemsCmd.Flags().Int("offset", 0, "Number of items to skip")
+	getListItemsCmd.Flags().String("sort", "", "Sort field (e.g. rank, score, released, title)")
+	getListItemsCmd.Flags().String("order", "", "Sort order: 'asc' or 'desc'")
+	getListItemsCmd.Flags().String("filter-genre", "", "Only return items of this genre")
+	getListItemsCmd.Flags().Bool("unified", false, "Ask the API for movies and shows as one list")
+	getListItemsCmd.Flags().StringToString("param", map[string]string{}, "Extra API query parameter as key=value (repeatable)")
+	getListItemsCmd.Flags().Bool("all", false, "Fetch every page and print them as one result")
+
+	getMediaInfoBatchCmd.Flags().StringP("from-file", "f", "", "Read media IDs from a file, one per line ('-' for stdin)")
+	getMediaInfoBatchCmd.Flags().Int("batch-size", client.MediaInfoBatchLimit, "Number of IDs sent per request")
+	getMediaInfoBatchCmd.Flags().StringSlice("append", []string{}, "Extra data to append to the response (e.g. keywords, reviews)")
+
+	getRatingsCmd.Flags().String("provider", "tmdb", "Provider of the given numeric IDs (tmdb, trakt, tvdb, mal)")
+	getRatingsCmd.Flags().StringP("from-file", "f", "", "Read media IDs from a file, one per line ('-' for stdin)")
+	getRatingsCmd.Flags().Int("batch-size", client.RatingsBatchLimit, "Number of IDs sent per request")
+	getRatingsCmd.Flags().Int("list-id", 0, "Rate the items of this list and print them annotated with the rating")
 
 	getWatchlistItemsCmd.Flags().String("sort", "", "Sort order (e.g., 'added_at.desc')")
 }
@@ -0,0 +1,215 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+	"github.com/luckylittle/mdblist-cli/internal/mdblisttest"
+)
+
+func TestGetAccount(t *testing.T) {
+	newServer(t)
+
+	var limits client.MyLimits
+	decode(t, mustRun(t, "get", "my-limits"), &limits)
+	if limits.UserID != mdblisttest.SampleUserID || limits.APIRequests != 1000 {
+		t.Errorf("my-limits = %+v", limits)
+	}
+
+	var activities client.LastActivities
+	decode(t, mustRun(t, "get", "last-activities"), &activities)
+	if activities.WatchlistedAt.IsZero() {
+		t.Errorf("last-activities = %+v", activities)
+	}
+
+	var watchlist client.WatchlistItems
+	decode(t, mustRun(t, "get", "watchlist-items", "--sort", "added_at.desc"), &watchlist)
+	if len(watchlist.Movies) != 1 || watchlist.Movies[0].ImdbID != mdblisttest.MatrixReloadedIMDb {
+		t.Errorf("watchlist-items = %+v", watchlist)
+	}
+}
+
+func TestGetLists(t *testing.T) {
+	newServer(t)
+	tests := []struct {
+		args []string
+		want []int
+	}{
+		{[]string{"get", "my-lists"}, []int{1, 2}},
+		{[]string{"get", "user-lists", "--id", "7"}, []int{1, 2}},
+		{[]string{"get", "user-lists", "--username", "bob"}, []int{1, 2}},
+		{[]string{"get", "list", "--id", "1"}, []int{1}},
+		{[]string{"get", "list", "--username", "bob", "--listname", "faves"}, []int{1}},
+		{[]string{"get", "top-lists"}, []int{2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var lists []client.List
+			decode(t, mustRun(t, tt.args...), &lists)
+			var ids []int
+			for _, l := range lists {
+				ids = append(ids, l.ID)
+			}
+			if !equalInts(ids, tt.want) {
+				t.Errorf("list IDs = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+
+	for _, args := range [][]string{
+		{"get", "user-lists"},
+		{"get", "list"},
+		{"get", "list", "--username", "bob"},
+	} {
+		if r := execute(t, "", args...); r.code != exitUsage {
+			t.Errorf("%v: exit code = %d, want %d", args, r.code, exitUsage)
+		}
+	}
+}
+
+func TestGetListItems(t *testing.T) {
+	srv := newServer(t)
+
+	var items client.ListItems
+	decode(t, mustRun(t, "get", "list-items", "--id", "1"), &items)
+	if len(items.Movies) != 1 || len(items.Shows) != 1 || items.Movies[0].Title != "The Matrix" {
+		t.Errorf("list-items = %+v", items)
+	}
+
+	decode(t, mustRun(t, "get", "list-items", "--username", "bob", "--listname", "faves", "--limit", "1"), &items)
+	if len(items.Movies) != 1 || len(items.Shows) != 0 {
+		t.Errorf("list-items --limit 1 = %+v", items)
+	}
+
+	// --all reads every page, --limit caps the total
+	long := client.ListItems{Movies: []client.ListItem{}, Shows: []client.ListItem{}}
+	for i := 1; i <= 2*listItemsPageSize+10; i++ {
+		long.Movies = append(long.Movies, client.ListItem{ID: 100000 + i, Rank: i, Title: "Movie " + strconv.Itoa(i), MediaType: "movie"})
+	}
+	srv.AddList(client.List{ID: 3, Name: "Long", Slug: "long", MediaType: "movie", Items: len(long.Movies), UserID: mdblisttest.SampleUserID}, long)
+	tests := []struct {
+		args     []string
+		items    int
+		requests int
+	}{
+		{[]string{"--all"}, 2*listItemsPageSize + 10, 3},
+		{[]string{"--all", "--limit", "510"}, 510, 2},
+		{[]string{"--all", "--limit", "5"}, 5, 1},
+		{[]string{"--all", "--offset", "1000", "--limit", "5"}, 5, 1},
+		{[]string{"--all", "--offset", "1015"}, 0, 1},
+	}
+	for _, tt := range tests {
+		before := srv.Requests()
+		decode(t, mustRun(t, append([]string{"get", "list-items", "--id", "3"}, tt.args...)...), &items)
+		if len(items.Movies) != tt.items {
+			t.Errorf("list-items %v returned %d items, want %d", tt.args, len(items.Movies), tt.items)
+		}
+		if n := srv.Requests() - before; n != tt.requests {
+			t.Errorf("list-items %v sent %d requests, want %d", tt.args, n, tt.requests)
+		}
+	}
+
+	r := mustRun(t, "get", "list-items", "--id", "1", "-o", "ndjson")
+	if lines := strings.Count(r.stdout, "\n"); lines != 2 {
+		t.Errorf("ndjson printed %d lines, want 2:\n%s", lines, r.stdout)
+	}
+
+	var changes client.ListChanges
+	decode(t, mustRun(t, "get", "list-changes", "1"), &changes)
+	if changes.ID != 1 {
+		t.Errorf("list-changes = %+v", changes)
+	}
+	if r := execute(t, "", "get", "list-changes", "one"); r.code != exitUsage {
+		t.Errorf("list-changes one: exit code = %d", r.code)
+	}
+}
+
+func TestGetMedia(t *testing.T) {
+	srv := newServer(t)
+
+	var info client.MediaInfo
+	decode(t, mustRun(t, "get", "media-info", "imdb", "movie", mdblisttest.MatrixIMDb), &info)
+	if info.Title != "The Matrix" || info.IDs.Tmdb != mdblisttest.MatrixTMDb {
+		t.Errorf("media-info = %+v", info)
+	}
+
+	var batch []client.MediaInfo
+	decode(t, mustRun(t, "get", "media-info-batch", "imdb", "movie", mdblisttest.MatrixIMDb, mdblisttest.MatrixReloadedIMDb), &batch)
+	if len(batch) != 2 {
+		t.Errorf("media-info-batch returned %d items, want 2", len(batch))
+	}
+
+	before := srv.Requests()
+	ids := writeFile(t, "ids.txt", mdblisttest.MatrixIMDb+"\n# comment\n"+mdblisttest.MatrixReloadedIMDb+"\n")
+	decode(t, mustRun(t, "get", "media-info-batch", "imdb", "movie", "-f", ids, "--batch-size", "1"), &batch)
+	if len(batch) != 2 || srv.Requests()-before != 2 {
+		t.Errorf("media-info-batch --batch-size 1 returned %d items in %d requests", len(batch), srv.Requests()-before)
+	}
+
+	r := execute(t, mdblisttest.MatrixIMDb+"\n", "get", "media-info-batch", "imdb", "movie", "-f", "-")
+	decode(t, r, &batch)
+	if len(batch) != 1 {
+		t.Errorf("media-info-batch -f - returned %d items, want 1", len(batch))
+	}
+	if r := execute(t, "", "get", "media-info-batch", "imdb", "movie"); r.code != exitUsage {
+		t.Errorf("media-info-batch without IDs: exit code = %d", r.code)
+	}
+}
+
+func TestGetRatings(t *testing.T) {
+	srv := newServer(t)
+
+	var ratings client.RatingsResponse
+	decode(t, mustRun(t, "get", "ratings", "movie", "imdb", "603", "604", "--batch-size", "1"), &ratings)
+	if len(ratings.Ratings) != 2 || ratings.Ratings[0].Rating != 8.7 || ratings.Ratings[1].Rating != 7.2 {
+		t.Errorf("ratings = %+v", ratings)
+	}
+
+	var rated []map[string]interface{}
+	decode(t, mustRun(t, "get", "ratings", "show", "imdb", "--list-id", "1"), &rated)
+	if len(rated) != 1 || rated[0]["title"] != "Dark" || rated[0]["rating"] != 8.7 {
+		t.Errorf("ratings --list-id = %v", rated)
+	}
+
+	// lists longer than a page are read in full
+	long := client.ListItems{Shows: []client.ListItem{}}
+	for i := 1; i <= listItemsPageSize+1; i++ {
+		long.Movies = append(long.Movies, client.ListItem{ID: 100000 + i, Rank: i, Title: "Movie " + strconv.Itoa(i), MediaType: "movie"})
+	}
+	long.Movies[listItemsPageSize].ID = mdblisttest.MatrixTMDb
+	srv.AddList(client.List{ID: 3, Name: "Long", Slug: "long", MediaType: "movie", Items: len(long.Movies), UserID: mdblisttest.SampleUserID}, long)
+	rated = nil
+	decode(t, mustRun(t, "get", "ratings", "movie", "imdb", "--list-id", "3"), &rated)
+	if len(rated) != listItemsPageSize+1 || rated[listItemsPageSize]["rating"] != 8.7 {
+		t.Errorf("ratings --list-id of a long list returned %d items", len(rated))
+	}
+
+	for _, args := range [][]string{
+		{"get", "ratings", "movie", "imdb"},
+		{"get", "ratings", "movie", "imdb", "abc"},
+		{"get", "ratings", "movie", "imdb", "--list-id", "1", "--provider", "trakt"},
+		{"get", "ratings", "movie", "imdb", "--list-id", "1", "603"},
+		{"get", "ratings", "movie", "imdb", "--list-id", "1", "--from-file", "ids.txt"},
+		{"get", "ratings", "movie", "imdb", "--provider", "imdb", "tt0133093"},
+		{"get", "ratings", "movie", "imdb", "--provider", "letterboxd", "603"},
+	} {
+		if r := execute(t, "", args...); r.code != exitUsage {
+			t.Errorf("%v: exit code = %d, want %d", args, r.code, exitUsage)
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
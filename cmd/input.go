@@ -0,0 +1,327 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+	"gopkg.in/yaml.v3"
+)
+
+// readIDs collects IDs from positional arguments and, optionally, from a
+// file with one ID per line. An argument or file name of "-" reads standard
+// input. Blank lines and lines starting with '#' are ignored.
+func readIDs(args []string, file string) ([]string, error) {
+	var ids []string
+	readStdin := file == "-"
+	for _, arg := range args {
+		if arg == "-" {
+			readStdin = true
+			continue
+		}
+		ids = append(ids, arg)
+	}
+
+	if file != "" && file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", file, err)
+		}
+		defer f.Close()
+		fileIDs, err := scanIDs(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		ids = append(ids, fileIDs...)
+	}
+
+	if readStdin {
+		stdinIDs, err := scanIDs(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read standard input: %w", err)
+		}
+		ids = append(ids, stdinIDs...)
+	}
+	return ids, nil
+}
+
+func scanIDs(r io.Reader) ([]string, error) {
+	var ids []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ids = append(ids, line)
+	}
+	return ids, scanner.Err()
+}
+
+// mediaEntry is a media ID read from the input, with its media type when
+// the input says what it is.
+type mediaEntry struct {
+	id        string
+	mediaType string
+}
+
+// readMediaEntries is readIDs for inputs that may also carry media types.
+// Files and standard input may hold plain ID lines (optionally followed by
+// a type), CSV or TSV with a header naming the ID and type columns, or the
+// JSON, NDJSON or YAML output of 'get list-items'.
+func readMediaEntries(args []string, file string) ([]mediaEntry, error) {
+	var entries []mediaEntry
+	readStdin := file == "-"
+	for _, arg := range args {
+		if arg == "-" {
+			readStdin = true
+			continue
+		}
+		entries = append(entries, mediaEntry{id: arg})
+	}
+
+	if file != "" && file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", file, err)
+		}
+		defer f.Close()
+		fileEntries, err := parseMediaEntries(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		entries = append(entries, fileEntries...)
+	}
+
+	if readStdin {
+		stdinEntries, err := parseMediaEntries(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read standard input: %w", err)
+		}
+		entries = append(entries, stdinEntries...)
+	}
+	return entries, nil
+}
+
+// parseMediaEntries detects the format of r from its first character or line.
+func parseMediaEntries(r io.Reader) ([]mediaEntry, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+	text := strings.TrimSpace(string(b))
+	switch {
+	case text == "":
+		return nil, nil
+	case text[0] == '{' || text[0] == '[':
+		return parseJSONEntries(b)
+	case isYAMLItems(text):
+		return parseYAMLEntries(b)
+	}
+	return parseLineEntries(text)
+}
+
+// isYAMLItems recognizes the -o yaml output of list commands: a mapping of
+// movies and shows, or a sequence of items.
+func isYAMLItems(text string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || line == "---" {
+			continue
+		}
+		return strings.HasPrefix(line, "movies:") || strings.HasPrefix(line, "shows:") || strings.HasPrefix(line, "- ")
+	}
+	return false
+}
+
+// parseJSONEntries accepts one or more JSON values: ListItems objects,
+// arrays of items, or single items as written by -o ndjson.
+func parseJSONEntries(b []byte) ([]mediaEntry, error) {
+	var entries []mediaEntry
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+			return entries, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+
+		var probe map[string]json.RawMessage
+		if json.Unmarshal(raw, &probe) == nil && (probe["id"] != nil || probe["imdb_id"] != nil) {
+			var item client.ListItem
+			if err := json.Unmarshal(raw, &item); err != nil {
+				return nil, fmt.Errorf("invalid item: %w", err)
+			}
+			entries = append(entries, listItemEntry(item, ""))
+			continue
+		}
+		var items client.ListItems
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, fmt.Errorf("invalid list items: %w", err)
+		}
+		entries = append(entries, listItemsEntries(&items)...)
+	}
+}
+
+func parseYAMLEntries(b []byte) ([]mediaEntry, error) {
+	if strings.HasPrefix(strings.TrimSpace(string(b)), "- ") {
+		var items []client.ListItem
+		if err := yaml.Unmarshal(b, &items); err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		return listItemsEntries(&client.ListItems{Movies: items}), nil
+	}
+	var items client.ListItems
+	if err := yaml.Unmarshal(b, &items); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	return listItemsEntries(&items), nil
+}
+
+func listItemsEntries(items *client.ListItems) []mediaEntry {
+	entries := make([]mediaEntry, 0, len(items.Movies)+len(items.Shows))
+	for _, item := range items.Movies {
+		entries = append(entries, listItemEntry(item, "movie"))
+	}
+	for _, item := range items.Shows {
+		entries = append(entries, listItemEntry(item, "show"))
+	}
+	return entries
+}
+
+// listItemEntry prefers the TMDb ID, which MDBList uses as the item ID.
+func listItemEntry(item client.ListItem, mediaType string) mediaEntry {
+	e := mediaEntry{id: item.ImdbID, mediaType: mediaType}
+	if item.ID > 0 {
+		e.id = strconv.Itoa(item.ID)
+	}
+	if item.MediaType != "" {
+		e.mediaType = item.MediaType
+	}
+	return e
+}
+
+// Header names recognized in CSV and TSV input, in order of preference.
+var (
+	idColumns   = []string{"tmdb_id", "ids.tmdb", "ids.tmdbid", "tmdb", "id", "imdb_id", "ids.imdb", "ids.imdbid", "imdb"}
+	typeColumns = []string{"mediatype", "media_type", "type"}
+)
+
+// parseLineEntries reads plain "id [type]" lines, or CSV/TSV when the first
+// line holds a comma or tab. Without a recognized header the first column
+// is the ID and the second the type.
+func parseLineEntries(text string) ([]mediaEntry, error) {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	var comma rune
+	switch {
+	case strings.Contains(lines[0], "\t"):
+		comma = '\t'
+	case strings.Contains(lines[0], ","):
+		comma = ','
+	default:
+		entries := make([]mediaEntry, 0, len(lines))
+		for _, line := range lines {
+			fields := strings.Fields(line)
+			e := mediaEntry{id: fields[0]}
+			if len(fields) > 1 {
+				e.mediaType = fields[1]
+			}
+			entries = append(entries, e)
+		}
+		return entries, nil
+	}
+
+	reader := csv.NewReader(strings.NewReader(strings.Join(lines, "\n")))
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	idCols, typeCol := headerColumns(records[0])
+	if len(idCols) > 0 {
+		records = records[1:]
+	} else {
+		idCols, typeCol = []int{0}, 1
+	}
+
+	entries := make([]mediaEntry, 0, len(records))
+	for i, record := range records {
+		var e mediaEntry
+		for _, col := range idCols {
+			if col < len(record) && strings.TrimSpace(record[col]) != "" {
+				e.id = strings.TrimSpace(record[col])
+				break
+			}
+		}
+		if e.id == "" {
+			return nil, fmt.Errorf("row %d has no ID", i+1)
+		}
+		if typeCol >= 0 && typeCol < len(record) {
+			e.mediaType = strings.TrimSpace(record[typeCol])
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// headerColumns finds the ID columns, most preferred first, and the type
+// column of a header row. typeCol is -1 when there is none.
+func headerColumns(header []string) (idCols []int, typeCol int) {
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range idColumns {
+		if i, ok := index[name]; ok {
+			idCols = append(idCols, i)
+		}
+	}
+	typeCol = -1
+	for _, name := range typeColumns {
+		if i, ok := index[name]; ok {
+			typeCol = i
+			break
+		}
+	}
+	return idCols, typeCol
+}
+
+// chunk splits s into consecutive slices of at most size elements.
+func chunk[T any](s []T, size int) [][]T {
+	if size <= 0 {
+		size = len(s)
+	}
+	var chunks [][]T
+	for len(s) > 0 {
+		n := size
+		if n > len(s) {
+			n = len(s)
+		}
+		chunks = append(chunks, s[:n])
+		s = s[n:]
+	}
+	return chunks
+}
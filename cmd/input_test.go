@@ -0,0 +1,45 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMediaEntries(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []mediaEntry
+	}{
+		{"empty", "", nil},
+		{"only comments", "# c\n", nil},
+		{"comments and blank lines", "# one\n\n  # two\n", nil},
+		{"lines", "# ids\ntt0133093\n603 movie\n\n70523 show\n", []mediaEntry{{id: "tt0133093"}, {id: "603", mediaType: "movie"}, {id: "70523", mediaType: "show"}}},
+		{"csv with header", "title,imdb_id,mediatype\nThe Matrix,tt0133093,movie\n", []mediaEntry{{id: "tt0133093", mediaType: "movie"}}},
+		{"tsv without header", "603\tmovie\n70523\tshow\n", []mediaEntry{{id: "603", mediaType: "movie"}, {id: "70523", mediaType: "show"}}},
+		{"json", `{"movies":[{"id":603,"imdb_id":"tt0133093"}],"shows":[{"imdb_id":"tt5753856"}]}`, []mediaEntry{{id: "603", mediaType: "movie"}, {id: "tt5753856", mediaType: "show"}}},
+		{"yaml", "shows:\n  - id: 70523\n", []mediaEntry{{id: "70523", mediaType: "show"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMediaEntries(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateListItemsCommentOnlyFile(t *testing.T) {
+	newServer(t)
+	ids := writeFile(t, "ids.txt", "# c\n")
+	r := execute(t, "", "update", "list-items", "--id", "1", "-a", "add", "-f", ids)
+	if r.code != exitUsage || !strings.Contains(r.stderr, "at least one movie or show ID") {
+		t.Errorf("exit code = %d, stderr:\n%s", r.code, r.stderr)
+	}
+}
@@ -0,0 +1,24 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// emitNDJSON writes v as one compact JSON line. Commands that stream results
+// pass it straight to the client so items are printed as they are decoded.
+func emitNDJSON[T any](v T) error {
+	return json.NewEncoder(os.Stdout).Encode(v)
+}
+
+// printNDJSON writes one line per record of an already decoded result.
+func printNDJSON(data interface{}) error {
+	for _, row := range rowsOf(data) {
+		if err := json.NewEncoder(os.Stdout).Encode(row.Interface()); err != nil {
+			return fmt.Errorf("formatting NDJSON: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+)
+
+// Changes reported in a plan.
+const (
+	changeAdd     = "add"
+	changeExists  = "exists"
+	changeRemove  = "remove"
+	changeMissing = "missing" // asked to remove, but not in the list
+)
+
+// planItem is one requested item and what would happen to it.
+type planItem struct {
+	Change    string `json:"change" yaml:"change"`
+	MediaType string `json:"mediatype" yaml:"mediatype"`
+	ID        string `json:"id" yaml:"id"`
+	Title     string `json:"title" yaml:"title"`
+}
+
+// itemsPlan is the --dry-run result of a list or watchlist modification.
+type itemsPlan struct {
+	ListID   int        `json:"list_id,omitempty" yaml:"list_id,omitempty"`
+	Action   string     `json:"action" yaml:"action"`
+	Add      int        `json:"add" yaml:"add"`
+	Existing int        `json:"existing" yaml:"existing"`
+	Remove   int        `json:"remove" yaml:"remove"`
+	Missing  int        `json:"missing" yaml:"missing"`
+	Items    []planItem `json:"items" yaml:"items"`
+}
+
+// renamePlan is the --dry-run result of update list-name.
+type renamePlan struct {
+	ListID  int    `json:"list_id" yaml:"list_id"`
+	Name    string `json:"name" yaml:"name"`
+	NewName string `json:"new_name" yaml:"new_name"`
+	Changed bool   `json:"changed" yaml:"changed"`
+}
+
+// itemIndex finds list items by media type and TMDb or IMDb ID.
+type itemIndex map[string]client.ListItem
+
+func newItemIndex(items []client.ListItem) itemIndex {
+	index := make(itemIndex, 2*len(items))
+	for _, item := range items {
+		mediaType := item.MediaType
+		if mediaType == "" {
+			mediaType = "movie"
+		}
+		if item.ID > 0 {
+			index[mediaType+":tmdb:"+strconv.Itoa(item.ID)] = item
+		}
+		if item.ImdbID != "" {
+			index[mediaType+":imdb:"+item.ImdbID] = item
+		}
+	}
+	return index
+}
+
+// lookup finds the item a request reference such as {"imdb": "tt0133093"}
+// points to.
+func (idx itemIndex) lookup(mediaType string, ref map[string]interface{}) (client.ListItem, string, bool) {
+	for _, provider := range []string{"tmdb", "imdb"} {
+		if v, ok := ref[provider]; ok {
+			id := fmt.Sprint(v)
+			item, found := idx[mediaType+":"+provider+":"+id]
+			return item, id, found
+		}
+	}
+	return client.ListItem{}, "", false
+}
+
+// planModification works out what action would do to the current items.
+func planModification(current []client.ListItem, action string, req client.ModifyListRequest) *itemsPlan {
+	plan := &itemsPlan{Action: action, Items: []planItem{}}
+	index := newItemIndex(current)
+	add := func(mediaType string, refs []map[string]interface{}) {
+		for _, ref := range refs {
+			item, id, found := index.lookup(mediaType, ref)
+			p := planItem{MediaType: mediaType, ID: id, Title: item.Title}
+			switch {
+			case action == "add" && found:
+				p.Change = changeExists
+				plan.Existing++
+			case action == "add":
+				p.Change = changeAdd
+				plan.Add++
+			case found:
+				p.Change = changeRemove
+				plan.Remove++
+			default:
+				p.Change = changeMissing
+				plan.Missing++
+			}
+			plan.Items = append(plan.Items, p)
+		}
+	}
+	add("movie", req.Movies)
+	add("show", req.Shows)
+	return plan
+}
+
+// fetchAllListItems reads every page of a list.
+func fetchAllListItems(ctx context.Context, listID int) ([]client.ListItem, error) {
+	params := url.Values{}
+	params.Set("limit", strconv.Itoa(listItemsPageSize))
+	var items []client.ListItem
+	it := apiClient.ListItemsIter(ctx, listID, params)
+	for it.Next() {
+		items = append(items, it.Item())
+	}
+	return items, it.Err()
+}
+
+// fetchAllWatchlistItems reads every page of the watchlist.
+func fetchAllWatchlistItems(ctx context.Context) ([]client.ListItem, error) {
+	params := url.Values{}
+	params.Set("limit", strconv.Itoa(listItemsPageSize))
+	var items []client.ListItem
+	it := apiClient.WatchlistItemsIter(ctx, params)
+	for it.Next() {
+		items = append(items, it.Item().ListItem)
+	}
+	return items, it.Err()
+}
+
+// printPlan prints a plan and a one-line summary on stderr, keeping stdout
+// for the plan itself.
+func printPlan(plan *itemsPlan) error {
+	fmt.Fprintf(os.Stderr, "Dry run: %d to add, %d already present, %d to remove, %d not present. Nothing was changed.\n",
+		plan.Add, plan.Existing, plan.Remove, plan.Missing)
+	return printData(plan)
+}
@@ -2,76 +2,343 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"time"
 
 	"github.com/luckylittle/mdblist-cli/internal/client"
+	"github.com/luckylittle/mdblist-cli/internal/config"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 )
 
 var (
-	apiClient *client.Client
-	output    string
+	apiClient     *client.Client
+	output        string
+	columns       []string
+	noHeaders     bool
+	timeout       time.Duration
+	cancelTimeout context.CancelFunc
+	retries       int
+	retryMaxWait  time.Duration
+	retryPOST     bool
+	rateLimit     float64
+	rateBurst     int
+	quotaCheck    string
+	verbose       bool
+	recordDir     string
+	replayDir     string
+
+	configPath  string
+	profileName string
+	cfg         *config.File
+	profile     *config.Profile
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "mdblist-cli",
 	Short: "A CLI for interacting with the MDBList API",
 	Long:  `A command-line interface to perform various actions against the MDBList RESTful API.`,
+	// Execute reports errors on stderr and picks the exit code
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	// Runnable so that unknownCommand replaces cobra's own subcommand check,
+	// whose error cannot be told apart from others
+	Args:                       unknownCommand,
+	SuggestionsMinimumDistance: 2,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if !cmd.HasParent() {
+			// only prints the help
+			return nil
+		}
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+		if _, ok := cfg.Profile(profileName); !ok && profileName != config.DefaultProfile {
+			return usageErrorf("profile %q not found in %s", profileName, configPath)
+		}
+		if err := checkOutputFormat(output); err != nil {
+			return err
+		}
+		switch quotaCheck {
+		case "off", "warn", "refuse":
+		default:
+			return usageErrorf("invalid --quota-check %q: must be off, warn or refuse", quotaCheck)
+		}
+
 		apiKey := viper.GetString("api_key")
+		opts := []client.Option{
+			client.WithBaseURL(viper.GetString("api_url")),
+			client.WithRetryPolicy(client.RetryPolicy{
+				MaxAttempts: retries + 1,
+				MaxWait:     retryMaxWait,
+				RetryPOST:   retryPOST,
+			}),
+			client.WithRateLimit(rateLimit, rateBurst),
+		}
+		switch {
+		case recordDir != "" && replayDir != "":
+			return usageErrorf("--record and --replay are mutually exclusive")
+		case recordDir != "":
+			opts = append(opts, client.WithRecorder(recordDir))
+		case replayDir != "":
+			// recordings never contain the key, so any placeholder will do
+			if apiKey == "" {
+				apiKey = "replay"
+			}
+			opts = append(opts, client.WithReplay(replayDir))
+		}
+
 		var err error
-		apiClient, err = client.New(apiKey)
+		apiClient, err = client.New(apiKey, opts...)
 		if err != nil {
-			return fmt.Errorf("failed to initialize API client: %w", err)
+			err = fmt.Errorf("failed to initialize API client: %w", err)
+			if apiKey == "" {
+				err = fmt.Errorf("%w; export MDBLIST_API_KEY or run 'mdblist-cli config set api_key <key>'", err)
+				return &codedError{kind: kindUnauthorized, err: err}
+			}
+			return err
+		}
+
+		if timeout > 0 {
+			var ctx context.Context
+			ctx, cancelTimeout = context.WithTimeout(cmd.Context(), timeout)
+			cmd.SetContext(ctx)
 		}
 		return nil
 	},
 }
 
 func Execute() {
-	err := rootCmd.Execute()
-	if err != nil {
-		os.Exit(1)
+	markUsageErrors(rootCmd)
+
+	// Ctrl-C cancels the command context, aborting any in-flight request
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx)
+	stop()
+	if code != exitOK {
+		os.Exit(code)
+	}
+}
+
+// run executes the command line, reports any error on stderr and returns the
+// exit code.
+func run(ctx context.Context) int {
+	cmd, err := rootCmd.ExecuteContextC(ctx)
+	if cancelTimeout != nil {
+		cancelTimeout()
+		cancelTimeout = nil
+	}
+	if err == nil {
+		return exitOK
+	}
+
+	code := exitCode(err)
+	if output == "json" && cmd.Flags().Changed("output") {
+		if writeJSONError(os.Stderr, err) == nil {
+			return code
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	if code == exitUsage {
+		fmt.Fprintf(os.Stderr, "Run '%s --help' for usage.\n", cmd.CommandPath())
+	}
+	return code
+}
+
+// unknownCommand rejects arguments given to the root command, which can only
+// be misspelt subcommands.
+func unknownCommand(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	var hint string
+	if suggestions := cmd.SuggestionsFor(args[0]); len(suggestions) > 0 {
+		hint = "\n\nDid you mean this?\n\t" + strings.Join(suggestions, "\n\t")
+	}
+	return usageErrorf("unknown command %q for %q%s", args[0], cmd.CommandPath(), hint)
+}
+
+// loadConfig reads the configuration file and selects the active profile.
+// Its settings become viper defaults, so flags and environment variables
+// still take precedence.
+func loadConfig(cmd *cobra.Command) error {
+	configPath = viper.GetString("config")
+	if configPath == "" {
+		var err error
+		if configPath, err = config.DefaultPath(); err != nil {
+			return err
+		}
 	}
+	var err error
+	if cfg, err = config.Load(configPath); err != nil {
+		return err
+	}
+
+	profileName = viper.GetString("profile")
+	if profileName == "" {
+		profileName = cfg.Current()
+	}
+	var ok bool
+	if profile, ok = cfg.Profile(profileName); !ok {
+		profile = &config.Profile{}
+	}
+	if profile.APIKey != "" {
+		viper.SetDefault("api_key", profile.APIKey)
+	}
+	if profile.APIURL != "" {
+		viper.SetDefault("api_url", profile.APIURL)
+	}
+	if !cmd.Flags().Changed("output") && profile.Output != "" {
+		output = profile.Output
+	}
+	logVerbose("Using profile %q from %s", profileName, configPath)
+	return nil
+}
+
+// listIDOrDefault returns --id, or the list_id of the active profile when
+// the list is not selected by any flag.
+func listIDOrDefault(cmd *cobra.Command) int {
+	id, _ := cmd.Flags().GetInt("id")
+	if id != 0 || cmd.Flags().Changed("username") || cmd.Flags().Changed("listname") {
+		return id
+	}
+	if id = profile.ListID; id != 0 {
+		logVerbose("Using list %d from profile %q", id, profileName)
+	}
+	return id
 }
 
 func init() {
-	// Read MDBLIST_API_KEY from environment variable
+	// Read MDBLIST_API_KEY, MDBLIST_API_URL, MDBLIST_PROFILE and
+	// MDBLIST_CONFIG from environment variables
 	viper.SetEnvPrefix("mdblist")
 	viper.BindEnv("api_key")
+	viper.BindEnv("api_url")
+	viper.BindEnv("profile")
+	viper.BindEnv("config")
+
+	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "json", "Output format (json, yaml, ndjson, table, csv, tsv, go-template=..., go-template-file=..., jsonpath=...)")
+	rootCmd.PersistentFlags().StringSliceVar(&columns, "columns", nil, "Comma-separated fields to show in table, csv and tsv output, e.g. title,release_year,ids.imdb")
+	rootCmd.PersistentFlags().BoolVar(&noHeaders, "no-headers", false, "Omit the header row in table, csv and tsv output")
+	rootCmd.PersistentFlags().String("api-url", client.DefaultBaseURL, "Base URL of the MDBList API (env MDBLIST_API_URL)")
+	viper.BindPFlag("api_url", rootCmd.PersistentFlags().Lookup("api-url"))
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Abort the command if it takes longer than this (e.g. 30s, 2m); 0 disables the timeout")
+	rootCmd.PersistentFlags().IntVar(&retries, "retries", 2, "Number of times to retry transient failures (429, 5xx, network errors)")
+	rootCmd.PersistentFlags().DurationVar(&retryMaxWait, "retry-max-wait", 30*time.Second, "Maximum time to wait between retries")
+	rootCmd.PersistentFlags().Float64Var(&rateLimit, "rate-limit", 0, "Maximum API requests per second; 0 disables the limiter")
+	rootCmd.PersistentFlags().IntVar(&rateBurst, "rate-burst", 1, "Number of requests allowed to burst above --rate-limit")
+	rootCmd.PersistentFlags().StringVar(&quotaCheck, "quota-check", "off", "Check the daily API quota before bulk operations (off, warn, refuse)")
+	rootCmd.PersistentFlags().StringVar(&recordDir, "record", "", "Record sanitized HTTP exchanges into this directory")
+	rootCmd.PersistentFlags().StringVar(&replayDir, "replay", "", "Replay HTTP exchanges recorded with --record from this directory instead of calling the API")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Print diagnostic information to stderr")
+	rootCmd.PersistentFlags().BoolVar(&retryPOST, "retry-post", false, "Also retry POST requests, which are not idempotent")
+	rootCmd.PersistentFlags().String("profile", "", "Configuration profile to use (env MDBLIST_PROFILE)")
+	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+	rootCmd.PersistentFlags().String("config", "", "Path of the configuration file (env MDBLIST_CONFIG, default ~/.config/mdblist-cli/config.yaml)")
+	viper.BindPFlag("config", rootCmd.PersistentFlags().Lookup("config"))
+
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &codedError{kind: kindUsage, err: err}
+	})
+}
 
-	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "json", "Output format (json, yaml)")
+func logVerbose(format string, args ...interface{}) {
+	if verbose {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
 }
 
-func printJSON(data interface{}) {
+// checkQuota guards a bulk operation that is about to make needed requests,
+// according to --quota-check. With the check on, the remaining quota is
+// logged in verbose mode.
+func checkQuota(ctx context.Context, needed int) error {
+	if quotaCheck == "off" {
+		return nil
+	}
+
+	quota, err := apiClient.CheckQuota(ctx, needed)
+	if quota != nil {
+		logVerbose("API quota: %d of %d requests used, %d remaining", quota.Used, quota.Limit, quota.Remaining())
+	}
+
+	var quotaErr *client.QuotaError
+	switch {
+	case err == nil:
+		return nil
+	case quotaCheck == "refuse":
+		return err
+	case quotaCheck == "warn" && errors.As(err, &quotaErr):
+		fmt.Fprintln(os.Stderr, "Warning:", err)
+	default:
+		logVerbose("Quota check: %v", err)
+	}
+	return nil
+}
+
+func printJSON(data interface{}) error {
 	b, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
-		fmt.Println("Error formatting JSON:", err)
-		return
+		return fmt.Errorf("formatting JSON: %w", err)
 	}
 	fmt.Println(string(b))
+	return nil
 }
 
-func printYAML(data interface{}) {
+func printYAML(data interface{}) error {
 	b, err := yaml.Marshal(data)
 	if err != nil {
-		fmt.Println("Error formatting YAML:", err)
-		return
+		return fmt.Errorf("formatting YAML: %w", err)
 	}
 	fmt.Println(string(b))
+	return nil
 }
 
-func printData(data interface{}) {
+func printData(data interface{}) error {
+	if err := checkOutputFormat(output); err != nil {
+		return err
+	}
 	switch output {
 	case "json":
-		printJSON(data)
+		return printJSON(data)
 	case "yaml":
-		printYAML(data)
-	default:
-		fmt.Printf("Error: unknown output format %q\n", output)
+		return printYAML(data)
+	case "table":
+		return printTable(data)
+	case "csv":
+		return printDelimited(data, ',')
+	case "tsv":
+		return printDelimited(data, '\t')
+	case "ndjson":
+		return printNDJSON(data)
+	}
+	kind, arg, _ := strings.Cut(output, "=")
+	return printTemplated(data, kind, arg)
+}
+
+// checkOutputFormat rejects an unknown output format, so commands can fail
+// before sending any request and profiles cannot store one.
+func checkOutputFormat(format string) error {
+	switch format {
+	case "json", "yaml", "table", "csv", "tsv", "ndjson":
+		return nil
+	case "go-template", "go-template-file", "jsonpath":
+		return usageErrorf("output format %q needs a value, e.g. %s=...", format, format)
+	}
+	if kind, _, ok := strings.Cut(format, "="); ok {
+		switch kind {
+		case "go-template", "go-template-file", "jsonpath":
+			return nil
+		}
 	}
+	return usageErrorf("unknown output format %q", format)
 }
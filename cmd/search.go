@@ -2,9 +2,9 @@
 package cmd
 
 import (
-	"fmt"
 	"net/url"
 
+	"github.com/luckylittle/mdblist-cli/internal/client"
 	"github.com/spf13/cobra"
 )
 
@@ -17,46 +17,46 @@ var searchMediaCmd = &cobra.Command{
 	Use:   "media <media-type>",
 	Short: "Search for movie, show or both (any).",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		mediaType := args[0]
 		query, _ := cmd.Flags().GetString("query")
 
 		if query == "" {
-			fmt.Println("Error: --query flag is required!")
-			return
+			return usageErrorf("--query flag is required")
 		}
 
 		params := url.Values{}
 		params.Set("query", query)
 
-		result, err := apiClient.SearchMedia(mediaType, params)
+		if output == "ndjson" {
+			return apiClient.StreamSearchMediaContext(cmd.Context(), mediaType, params, emitNDJSON[client.SearchItem])
+		}
+
+		result, err := apiClient.SearchMediaContext(cmd.Context(), mediaType, params)
 		if err != nil {
-			fmt.Println("Error:", err)
-			return
+			return err
 		}
-		printData(result)
+		return printData(result)
 	},
 }
 
 var searchListsCmd = &cobra.Command{
 	Use:   "lists",
 	Short: "Search public lists by title.",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		query, _ := cmd.Flags().GetString("query")
 		if query == "" {
-			fmt.Println("Error: --query flag is required!")
-			return
+			return usageErrorf("--query flag is required")
 		}
 
 		params := url.Values{}
 		params.Set("query", query)
 
-		lists, err := apiClient.SearchLists(params)
+		lists, err := apiClient.SearchListsContext(cmd.Context(), params)
 		if err != nil {
-			fmt.Println("Error:", err)
-			return
+			return err
 		}
-		printData(lists)
+		return printData(lists)
 	},
 }
 
@@ -0,0 +1,42 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+)
+
+func TestSearchMedia(t *testing.T) {
+	newServer(t)
+
+	var result client.SearchResult
+	decode(t, mustRun(t, "search", "media", "movie", "-q", "matrix"), &result)
+	if result.Total != 2 || len(result.Search) != 2 || result.Search[0].Title != "The Matrix" {
+		t.Errorf("search media = %+v", result)
+	}
+
+	r := mustRun(t, "search", "media", "any", "-q", "dark", "-o", "ndjson")
+	if strings.Count(r.stdout, "\n") != 1 || !strings.Contains(r.stdout, `"title":"Dark"`) {
+		t.Errorf("search media -o ndjson =\n%s", r.stdout)
+	}
+
+	if r := execute(t, "", "search", "media", "movie"); r.code != exitUsage {
+		t.Errorf("search media without --query: exit code = %d", r.code)
+	}
+}
+
+func TestSearchLists(t *testing.T) {
+	newServer(t)
+
+	var lists []client.List
+	decode(t, mustRun(t, "search", "lists", "-q", "fav"), &lists)
+	if len(lists) != 1 || lists[0].Slug != "faves" {
+		t.Errorf("search lists = %+v", lists)
+	}
+
+	if r := execute(t, "", "search", "lists"); r.code != exitUsage {
+		t.Errorf("search lists without --query: exit code = %d", r.code)
+	}
+}
@@ -0,0 +1,291 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+	"github.com/luckylittle/mdblist-cli/internal/snapshot"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Changes reported by snapshot diff.
+const (
+	changeAdded   = "added"
+	changeRemoved = "removed"
+)
+
+// snapshotChange is an item added to or removed from a list between two
+// snapshots.
+type snapshotChange struct {
+	Change          string `json:"change" yaml:"change"`
+	client.ListItem `yaml:",inline"`
+}
+
+// snapshotDiff is the result of snapshot diff.
+type snapshotDiff struct {
+	ListID  int              `json:"list_id" yaml:"list_id"`
+	From    time.Time        `json:"from" yaml:"from"`
+	To      time.Time        `json:"to" yaml:"to"`
+	Added   int              `json:"added" yaml:"added"`
+	Removed int              `json:"removed" yaml:"removed"`
+	Items   []snapshotChange `json:"items" yaml:"items"`
+}
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Keep local snapshots of lists and compare them over time.",
+	Long: `Keep timestamped copies of the items of a list on disk and compare them.
+
+Snapshots are stored as JSON files in $XDG_DATA_HOME/mdblist-cli/snapshots
+(~/.local/share/mdblist-cli/snapshots by default), one directory per list.
+Run 'snapshot take' regularly, e.g. from cron, then use 'snapshot history'
+and 'snapshot diff' to see how the list changed. Only 'take' calls the API.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if cmd == snapshotTakeCmd {
+			return rootCmd.PersistentPreRunE(cmd, args)
+		}
+		// history and diff only read local files
+		return loadConfig(cmd)
+	},
+}
+
+var snapshotTakeCmd = &cobra.Command{
+	Use:   "take",
+	Short: "Save the current items of a list as a new snapshot.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listID := listIDOrDefault(cmd)
+		if listID == 0 {
+			return usageErrorf("--id is required")
+		}
+		store, err := snapshotStore()
+		if err != nil {
+			return err
+		}
+
+		lists, err := apiClient.GetListByIDContext(cmd.Context(), listID)
+		if err != nil {
+			return err
+		}
+		if len(lists) == 0 {
+			return fmt.Errorf("list %d: %w", listID, client.ErrNotFound)
+		}
+		items, err := fetchAllListItems(cmd.Context(), listID)
+		if err != nil {
+			return err
+		}
+
+		snap := &snapshot.Snapshot{
+			ListID:   listID,
+			ListName: lists[0].Name,
+			TakenAt:  time.Now().UTC(),
+			Items:    client.ListItems{Movies: []client.ListItem{}, Shows: []client.ListItem{}},
+		}
+		for _, item := range items {
+			if item.MediaType == "show" {
+				snap.Items.Shows = append(snap.Items.Shows, item)
+			} else {
+				snap.Items.Movies = append(snap.Items.Movies, item)
+			}
+		}
+		path, err := store.Save(snap)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Saved snapshot of list %d (%s): %d movies, %d shows.\n",
+			listID, snap.ListName, len(snap.Items.Movies), len(snap.Items.Shows))
+		return printData(&snapshot.Info{
+			ListID:   snap.ListID,
+			ListName: snap.ListName,
+			TakenAt:  snap.TakenAt,
+			Movies:   len(snap.Items.Movies),
+			Shows:    len(snap.Items.Shows),
+			Path:     path,
+		})
+	},
+}
+
+var snapshotHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the snapshots of a list, oldest first.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listID := listIDOrDefault(cmd)
+		if listID == 0 {
+			return usageErrorf("--id is required")
+		}
+		store, err := snapshotStore()
+		if err != nil {
+			return err
+		}
+		infos, err := store.List(listID)
+		if err != nil {
+			return err
+		}
+		if len(infos) == 0 {
+			fmt.Fprintf(os.Stderr, "No snapshots of list %d in %s.\n", listID, store.Dir)
+		}
+		if infos == nil {
+			infos = []snapshot.Info{}
+		}
+		return printData(infos)
+	},
+}
+
+var snapshotDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show the titles added and removed between two snapshots.",
+	Long: `Show the movies and shows added to and removed from a list between two
+snapshots. --from and --to select the latest snapshot taken at or before the
+given time, which can be RFC 3339 (2024-05-01T12:00:00Z), a local date and
+time (2024-05-01 12:00), a date (2024-05-01) or an age (36h, 7d).
+
+Without --to the latest snapshot is used; without --from, the one before it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listID := listIDOrDefault(cmd)
+		if listID == 0 {
+			return usageErrorf("--id is required")
+		}
+		fromFlag, _ := cmd.Flags().GetString("from")
+		toFlag, _ := cmd.Flags().GetString("to")
+		store, err := snapshotStore()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		to := now
+		if toFlag != "" {
+			if to, err = parseSnapshotTime(toFlag, now); err != nil {
+				return err
+			}
+		}
+		toInfo, err := store.At(listID, to)
+		if err != nil {
+			return snapshotError(err)
+		}
+
+		var fromInfo *snapshot.Info
+		if fromFlag != "" {
+			from, err := parseSnapshotTime(fromFlag, now)
+			if err != nil {
+				return err
+			}
+			if fromInfo, err = store.At(listID, from); err != nil {
+				return snapshotError(err)
+			}
+		} else if fromInfo, err = store.At(listID, toInfo.TakenAt.Add(-time.Nanosecond)); err != nil {
+			if errors.Is(err, snapshot.ErrNoSnapshot) {
+				err = fmt.Errorf("%w before %s for list %d, take another snapshot or pass --from",
+					snapshot.ErrNoSnapshot, toInfo.TakenAt.Format(time.RFC3339), listID)
+			}
+			return snapshotError(err)
+		}
+		if fromInfo.TakenAt.After(toInfo.TakenAt) {
+			return usageErrorf("--from selects a snapshot taken after the one selected by --to")
+		}
+
+		fromSnap, err := store.Load(fromInfo.Path)
+		if err != nil {
+			return err
+		}
+		toSnap, err := store.Load(toInfo.Path)
+		if err != nil {
+			return err
+		}
+		d := snapshot.Compare(fromSnap, toSnap)
+
+		result := &snapshotDiff{
+			ListID:  listID,
+			From:    fromSnap.TakenAt,
+			To:      toSnap.TakenAt,
+			Added:   len(d.Added),
+			Removed: len(d.Removed),
+			Items:   []snapshotChange{},
+		}
+		for _, item := range d.Added {
+			result.Items = append(result.Items, snapshotChange{Change: changeAdded, ListItem: item})
+		}
+		for _, item := range d.Removed {
+			result.Items = append(result.Items, snapshotChange{Change: changeRemoved, ListItem: item})
+		}
+		fmt.Fprintf(os.Stderr, "List %d from %s to %s: %d added, %d removed.\n", listID,
+			result.From.Format(time.RFC3339), result.To.Format(time.RFC3339), result.Added, result.Removed)
+		return printData(result)
+	},
+}
+
+// snapshotStore opens the snapshot directory selected by --snapshot-dir,
+// MDBLIST_SNAPSHOT_DIR or the default location.
+func snapshotStore() (snapshot.Store, error) {
+	dir := viper.GetString("snapshot_dir")
+	if dir == "" {
+		var err error
+		if dir, err = snapshot.DefaultDir(); err != nil {
+			return snapshot.Store{}, err
+		}
+	}
+	logVerbose("Using snapshots in %s", dir)
+	return snapshot.Store{Dir: dir}, nil
+}
+
+// snapshotError reports a missing snapshot as not found.
+func snapshotError(err error) error {
+	if errors.Is(err, snapshot.ErrNoSnapshot) {
+		return &codedError{kind: kindNotFound, err: err}
+	}
+	return err
+}
+
+// snapshotTimeLayouts are the absolute times accepted by --from and --to.
+// Layouts without a zone are read in local time.
+var snapshotTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseSnapshotTime reads an absolute time or an age such as 36h or 7d.
+func parseSnapshotTime(s string, now time.Time) (time.Time, error) {
+	for _, layout := range snapshotTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, usageErrorf("invalid time %q: use RFC 3339, a date such as 2024-05-01, or an age such as 36h or 7d", s)
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.AddCommand(snapshotTakeCmd)
+	snapshotCmd.AddCommand(snapshotHistoryCmd)
+	snapshotCmd.AddCommand(snapshotDiffCmd)
+
+	snapshotCmd.PersistentFlags().String("snapshot-dir", "", "Directory holding the snapshots (env MDBLIST_SNAPSHOT_DIR, default ~/.local/share/mdblist-cli/snapshots)")
+	viper.BindPFlag("snapshot_dir", snapshotCmd.PersistentFlags().Lookup("snapshot-dir"))
+	viper.BindEnv("snapshot_dir")
+
+	snapshotCmd.PersistentFlags().Int("id", 0, "List ID")
+
+	snapshotDiffCmd.Flags().String("from", "", "Compare from the latest snapshot at or before this time (default: the snapshot before --to)")
+	snapshotDiffCmd.Flags().String("to", "", "Compare to the latest snapshot at or before this time (default: the latest snapshot)")
+}
@@ -0,0 +1,77 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+	"github.com/luckylittle/mdblist-cli/internal/snapshot"
+)
+
+func TestSnapshot(t *testing.T) {
+	srv := newServer(t)
+	dir := t.TempDir()
+	t.Setenv("MDBLIST_SNAPSHOT_DIR", dir)
+
+	// two days ago the list only held Dark
+	items := srv.ListItems(1)
+	old := &snapshot.Snapshot{ListID: 1, ListName: "Faves", TakenAt: time.Now().Add(-48 * time.Hour).UTC().Truncate(time.Second), Items: client.ListItems{Movies: []client.ListItem{}, Shows: items.Shows}}
+	if _, err := (snapshot.Store{Dir: dir}).Save(old); err != nil {
+		t.Fatal(err)
+	}
+
+	var info snapshot.Info
+	decode(t, mustRun(t, "snapshot", "take", "--id", "1"), &info)
+	if info.ListID != 1 || info.Movies != 1 || info.Shows != 1 {
+		t.Errorf("snapshot take = %+v", info)
+	}
+
+	var history []snapshot.Info
+	decode(t, mustRun(t, "snapshot", "history", "--id", "1"), &history)
+	if len(history) != 2 || !history[0].TakenAt.Equal(old.TakenAt) {
+		t.Errorf("snapshot history = %+v", history)
+	}
+
+	for _, args := range [][]string{
+		{"snapshot", "diff", "--id", "1"},
+		{"snapshot", "diff", "--id", "1", "--from", "1d"},
+	} {
+		var diff snapshotDiff
+		decode(t, mustRun(t, args...), &diff)
+		if diff.Added != 1 || diff.Removed != 0 || diff.Items[0].Title != "The Matrix" {
+			t.Errorf("%v = %+v", args, diff)
+		}
+	}
+
+	// a second snapshot within the same second is kept next to the first
+	var again snapshot.Info
+	decode(t, mustRun(t, "snapshot", "take", "--id", "1"), &again)
+	if again.Path == info.Path {
+		t.Errorf("second snapshot overwrote %s", info.Path)
+	}
+	var diff snapshotDiff
+	decode(t, mustRun(t, "snapshot", "diff", "--id", "1"), &diff)
+	if diff.Added != 0 || diff.Removed != 0 || !diff.From.Equal(info.TakenAt) {
+		t.Errorf("diff of the last two snapshots = %+v", diff)
+	}
+
+	// history and diff work offline
+	t.Setenv("MDBLIST_API_KEY", "")
+	mustRun(t, "snapshot", "history", "--id", "1", "--snapshot-dir", dir)
+
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{"snapshot", "history"}, exitUsage},
+		{[]string{"snapshot", "diff", "--id", "1", "--from", "yesterday"}, exitUsage},
+		{[]string{"snapshot", "diff", "--id", "1", "--from", "3d"}, exitNotFound},
+		{[]string{"snapshot", "diff", "--id", "2"}, exitNotFound},
+	}
+	for _, tt := range tests {
+		if r := execute(t, "", tt.args...); r.code != tt.code {
+			t.Errorf("%v: exit code = %d, want %d; stderr:\n%s", tt.args, r.code, tt.code, r.stderr)
+		}
+	}
+}
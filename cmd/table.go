@@ -0,0 +1,132 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const (
+	tableColumnGap    = 3
+	tableMinColWidth  = 8
+	defaultTermWidth  = 80
+	tableTruncateMark = "…"
+)
+
+// terminalWidth returns $COLUMNS, or the width of the terminal on stdout,
+// or 0 (unlimited) when output is redirected.
+func terminalWidth() int {
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		return w
+	}
+	fd := int(os.Stdout.Fd())
+	if !term.IsTerminal(fd) {
+		return 0
+	}
+	if w, _, err := term.GetSize(fd); err == nil && w > 0 {
+		return w
+	}
+	return defaultTermWidth
+}
+
+func printTable(data interface{}) error {
+	if err := writeTable(os.Stdout, data, terminalWidth()); err != nil {
+		return fmt.Errorf("formatting table: %w", err)
+	}
+	return nil
+}
+
+// writeTable renders data as aligned columns. When maxWidth is positive the
+// widest columns are truncated until the table fits.
+func writeTable(w io.Writer, data interface{}, maxWidth int) error {
+	rows := rowsOf(data)
+	cols := selectColumns(rows)
+	if len(cols) == 0 {
+		return nil
+	}
+
+	table := make([][]string, 0, len(rows)+1)
+	if !noHeaders {
+		header := make([]string, len(cols))
+		for i, col := range cols {
+			header[i] = strings.ToUpper(strings.ReplaceAll(col, ".", "_"))
+		}
+		table = append(table, header)
+	}
+	for _, row := range rows {
+		cells, err := rowCells(row, cols)
+		if err != nil {
+			return err
+		}
+		for i, cell := range cells {
+			cells[i] = strings.Join(strings.Fields(cell), " ")
+		}
+		table = append(table, cells)
+	}
+
+	widths := make([]int, len(cols))
+	for _, cells := range table {
+		for i, cell := range cells {
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	if maxWidth > 0 {
+		fitWidths(widths, maxWidth)
+	}
+
+	for _, cells := range table {
+		var b strings.Builder
+		for i, cell := range cells {
+			cell = truncate(cell, widths[i])
+			b.WriteString(cell)
+			if i < len(cells)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+tableColumnGap))
+			}
+		}
+		fmt.Fprintln(w, b.String())
+	}
+	return nil
+}
+
+// fitWidths shrinks the widest column one step at a time until the row fits
+// maxWidth or every column is down to tableMinColWidth.
+func fitWidths(widths []int, maxWidth int) {
+	total := func() int {
+		sum := tableColumnGap * (len(widths) - 1)
+		for _, w := range widths {
+			sum += w
+		}
+		return sum
+	}
+	for total() > maxWidth {
+		widest := 0
+		for i, w := range widths {
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= tableMinColWidth {
+			return
+		}
+		widths[widest]--
+	}
+}
+
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	if width <= 1 {
+		return tableTruncateMark
+	}
+	runes := []rune(s)
+	return string(runes[:width-1]) + tableTruncateMark
+}
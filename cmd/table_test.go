@@ -0,0 +1,35 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTerminalWidth(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stdout := os.Stdout
+	os.Stdout = f
+	defer func() { os.Stdout = stdout }()
+
+	tests := []struct {
+		columns string
+		want    int
+	}{
+		{"", 0}, // redirected output is not truncated
+		{"120", 120},
+		{"abc", 0},
+		{"-5", 0},
+	}
+	for _, tt := range tests {
+		t.Setenv("COLUMNS", tt.columns)
+		if got := terminalWidth(); got != tt.want {
+			t.Errorf("COLUMNS=%q: terminalWidth() = %d, want %d", tt.columns, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+// genericData converts a result into the maps and slices of its JSON form,
+// so templates and JSONPath expressions use the same keys as -o json.
+func genericData(data interface{}) (interface{}, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
+
+var templateFuncs = template.FuncMap{
+	"join":           tmplJoin,
+	"default":        tmplDefault,
+	"truncate":       tmplTruncate,
+	"ratingBySource": tmplRatingBySource,
+}
+
+// tmplJoin joins the elements of a list: {{join ", " .genres}}
+func tmplJoin(sep string, list interface{}) string {
+	items, ok := list.([]interface{})
+	if !ok {
+		return jsonPathText(list)
+	}
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = jsonPathText(item)
+	}
+	return strings.Join(parts, sep)
+}
+
+// tmplDefault returns def when value is missing or empty: {{default "n/a" .tvdb_id}}
+func tmplDefault(def, value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return def
+	case string:
+		if v == "" {
+			return def
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			return def
+		}
+	}
+	return value
+}
+
+// tmplTruncate shortens s to at most n characters: {{truncate 30 .title}}
+func tmplTruncate(n int, s interface{}) string {
+	text := jsonPathText(s)
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	return truncate(text, n)
+}
+
+// tmplRatingBySource picks a value out of MediaInfo ratings:
+// {{ratingBySource .ratings "imdb"}}
+func tmplRatingBySource(ratings interface{}, source string) interface{} {
+	items, _ := ratings.([]interface{})
+	for _, item := range items {
+		if r, ok := item.(map[string]interface{}); ok && r["source"] == source {
+			return r["value"]
+		}
+	}
+	return nil
+}
+
+// printTemplated handles the go-template=, go-template-file= and jsonpath=
+// output formats.
+func printTemplated(data interface{}, kind, arg string) error {
+	var err error
+	switch kind {
+	case "go-template":
+		err = writeGoTemplate(os.Stdout, data, arg)
+	case "go-template-file":
+		var b []byte
+		if b, err = os.ReadFile(arg); err == nil {
+			err = writeGoTemplate(os.Stdout, data, string(b))
+		}
+	case "jsonpath":
+		err = writeJSONPath(os.Stdout, data, arg)
+	default:
+		return usageErrorf("unknown output format %q", output)
+	}
+	if err != nil {
+		return fmt.Errorf("executing %s: %w", kind, err)
+	}
+	return nil
+}
+
+func writeGoTemplate(w io.Writer, data interface{}, text string) error {
+	tmpl, err := template.New("output").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return err
+	}
+	generic, err := genericData(data)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, generic)
+}
+
+// writeJSONPath evaluates a kubectl-style JSONPath template such as
+// '{.movies[*].imdb_id}' or '{range .shows[*]}{.title}{"\n"}{end}'. Text
+// outside braces is printed as is; several results of one expression are
+// separated by spaces.
+func writeJSONPath(w io.Writer, data interface{}, expr string) error {
+	generic, err := genericData(data)
+	if err != nil {
+		return err
+	}
+	segments, err := splitJSONPath(expr)
+	if err != nil {
+		return err
+	}
+	var out strings.Builder
+	if _, err := execJSONPath(&out, segments, generic); err != nil {
+		return err
+	}
+	// no trailing newline, as with kubectl, so $(...) joins stay intact
+	_, err = io.WriteString(w, out.String())
+	return err
+}
+
+type jsonPathSegment struct {
+	literal string
+	expr    string
+	isExpr  bool
+}
+
+func splitJSONPath(s string) ([]jsonPathSegment, error) {
+	var segments []jsonPathSegment
+	for s != "" {
+		start := strings.Index(s, "{")
+		if start < 0 {
+			segments = append(segments, jsonPathSegment{literal: s})
+			break
+		}
+		if start > 0 {
+			segments = append(segments, jsonPathSegment{literal: s[:start]})
+		}
+		end := matchingBrace(s, start)
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed '{' in JSONPath %q", s)
+		}
+		segments = append(segments, jsonPathSegment{expr: strings.TrimSpace(s[start+1 : end]), isExpr: true})
+		s = s[end+1:]
+	}
+	return segments, nil
+}
+
+// matchingBrace finds the '}' closing the '{' at start, skipping quoted text.
+func matchingBrace(s string, start int) int {
+	inQuote := false
+	for i := start + 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && inQuote:
+			i++
+		case s[i] == '"':
+			inQuote = !inQuote
+		case s[i] == '}' && !inQuote:
+			return i
+		}
+	}
+	return -1
+}
+
+// execJSONPath runs segments against data and returns how many segments it
+// consumed, so that {range} blocks can stop at their {end}.
+func execJSONPath(out *strings.Builder, segments []jsonPathSegment, data interface{}) (int, error) {
+	for i := 0; i < len(segments); i++ {
+		seg := segments[i]
+		switch {
+		case !seg.isExpr:
+			out.WriteString(seg.literal)
+		case seg.expr == "end":
+			return i + 1, nil
+		case strings.HasPrefix(seg.expr, "range "):
+			nodes, err := evalJSONPath(strings.TrimSpace(strings.TrimPrefix(seg.expr, "range ")), data)
+			if err != nil {
+				return 0, err
+			}
+			body := segments[i+1:]
+			consumed := 0
+			if len(nodes) == 0 {
+				// still need to find the matching {end}
+				var discard strings.Builder
+				consumed, err = execJSONPath(&discard, body, nil)
+				if err != nil {
+					return 0, err
+				}
+			}
+			for _, node := range nodes {
+				consumed, err = execJSONPath(out, body, node)
+				if err != nil {
+					return 0, err
+				}
+			}
+			i += consumed
+		case strings.HasPrefix(seg.expr, `"`):
+			text, err := strconv.Unquote(seg.expr)
+			if err != nil {
+				return 0, fmt.Errorf("invalid string literal %s", seg.expr)
+			}
+			out.WriteString(text)
+		default:
+			nodes, err := evalJSONPath(seg.expr, data)
+			if err != nil {
+				return 0, err
+			}
+			parts := make([]string, len(nodes))
+			for j, node := range nodes {
+				parts[j] = jsonPathText(node)
+			}
+			out.WriteString(strings.Join(parts, " "))
+		}
+	}
+	return len(segments), nil
+}
+
+// evalJSONPath supports .field, [n], [*] and [?(@.key==value)] steps.
+func evalJSONPath(expr string, data interface{}) ([]interface{}, error) {
+	expr = strings.TrimPrefix(expr, "$")
+	nodes := []interface{}{data}
+	for expr != "" {
+		var next []interface{}
+		switch {
+		case expr[0] == '.':
+			end := strings.IndexAny(expr[1:], ".[")
+			if end < 0 {
+				end = len(expr) - 1
+			}
+			key := expr[1 : end+1]
+			expr = expr[end+1:]
+			if key == "" {
+				continue
+			}
+			for _, node := range nodes {
+				if m, ok := node.(map[string]interface{}); ok {
+					if v, ok := m[key]; ok {
+						next = append(next, v)
+					}
+				}
+			}
+		case expr[0] == '[':
+			end := strings.Index(expr, "]")
+			if strings.HasPrefix(expr, "[?(") {
+				end = strings.Index(expr, ")]") + 1
+			}
+			if end <= 0 {
+				return nil, fmt.Errorf("unclosed '[' in JSONPath expression")
+			}
+			sel := expr[1:end]
+			expr = expr[end+1:]
+			for _, node := range nodes {
+				selected, err := selectJSONPath(node, sel)
+				if err != nil {
+					return nil, err
+				}
+				next = append(next, selected...)
+			}
+		default:
+			return nil, fmt.Errorf("unexpected %q in JSONPath expression", expr)
+		}
+		nodes = next
+	}
+	return nodes, nil
+}
+
+func selectJSONPath(node interface{}, sel string) ([]interface{}, error) {
+	switch n := node.(type) {
+	case []interface{}:
+		switch {
+		case sel == "*":
+			return n, nil
+		case strings.HasPrefix(sel, "?(") && strings.HasSuffix(sel, ")"):
+			var out []interface{}
+			for _, item := range n {
+				ok, err := matchJSONPathFilter(item, sel[2:len(sel)-1])
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					out = append(out, item)
+				}
+			}
+			return out, nil
+		}
+		i, err := strconv.Atoi(sel)
+		if err != nil {
+			return nil, fmt.Errorf("invalid array index %q", sel)
+		}
+		if i < 0 {
+			i += len(n)
+		}
+		if i < 0 || i >= len(n) {
+			return nil, nil
+		}
+		return []interface{}{n[i]}, nil
+	case map[string]interface{}:
+		if sel == "*" {
+			out := make([]interface{}, 0, len(n))
+			for _, v := range n {
+				out = append(out, v)
+			}
+			return out, nil
+		}
+		if v, ok := n[strings.Trim(sel, `'"`)]; ok {
+			return []interface{}{v}, nil
+		}
+	}
+	return nil, nil
+}
+
+// matchJSONPathFilter evaluates "@.key==value" or "@.key!=value".
+func matchJSONPathFilter(item interface{}, filter string) (bool, error) {
+	op := "=="
+	idx := strings.Index(filter, op)
+	if idx < 0 {
+		op = "!="
+		idx = strings.Index(filter, op)
+	}
+	if idx < 0 {
+		return false, fmt.Errorf("unsupported JSONPath filter %q", filter)
+	}
+	left := strings.TrimSpace(filter[:idx])
+	right := strings.TrimSpace(filter[idx+len(op):])
+	if !strings.HasPrefix(left, "@") {
+		return false, errors.New("JSONPath filters must start with @")
+	}
+	if unquoted, err := strconv.Unquote(right); err == nil {
+		right = unquoted
+	} else {
+		right = strings.Trim(right, "'")
+	}
+
+	values, err := evalJSONPath(strings.TrimPrefix(left, "@"), item)
+	if err != nil {
+		return false, err
+	}
+	equal := len(values) == 1 && jsonPathText(values[0]) == right
+	return equal == (op == "=="), nil
+}
+
+func jsonPathText(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
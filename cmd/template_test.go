@@ -0,0 +1,114 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+// templateData mimics the list-items and media-info output seen by templates.
+var templateData = map[string]interface{}{
+	"movies": []interface{}{
+		map[string]interface{}{"title": "The Matrix", "imdb_id": "tt0133093", "rank": 1, "genres": []string{"action", "sci-fi"}},
+		map[string]interface{}{"title": "The Matrix Reloaded", "imdb_id": "tt0234215", "rank": 2, "tvdb_id": nil},
+		map[string]interface{}{"title": "Dune: Part Two", "imdb_id": "tt15239678", "rank": 3, "ratings": []interface{}{
+			map[string]interface{}{"source": "imdb", "value": 8.6},
+			map[string]interface{}{"source": "tomatoes", "value": 92},
+		}},
+	},
+	"shows": []interface{}{},
+}
+
+func TestWriteJSONPath(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{"field", "{.movies[0].title}", "The Matrix"},
+		{"dollar root", "{$.movies[1].imdb_id}", "tt0234215"},
+		{"wildcard", "{.movies[*].rank}", "1 2 3"},
+		{"negative index", "{.movies[-1].title}", "Dune: Part Two"},
+		{"index out of range", "{.movies[5].title}", ""},
+		{"bracket key", "{.movies[0]['imdb_id']}", "tt0133093"},
+		{"missing field", "{.movies[0].nope}", ""},
+		{"nested array", "{.movies[0].genres}", `["action","sci-fi"]`},
+		{"literal text", "id={.movies[0].imdb_id};", "id=tt0133093;"},
+		{"string literal", `{.movies[0].rank}{"\t"}{.movies[1].rank}`, "1\t2"},
+		{"range", `{range .movies[*]}{.imdb_id}{"\n"}{end}`, "tt0133093\ntt0234215\ntt15239678\n"},
+		{"range with text after end", `{range .movies[*]}[{.rank}]{end} done`, "[1][2][3] done"},
+		{"empty range", `{range .shows[*]}{.title}{"\n"}{end}none`, "none"},
+		{"filter", `{.movies[?(@.imdb_id=="tt0234215")].title}`, "The Matrix Reloaded"},
+		{"filter single quotes", `{.movies[?(@.imdb_id=='tt0234215')].rank}`, "2"},
+		{"filter not equal", `{.movies[?(@.rank!=2)].rank}`, "1 3"},
+		{"filter nested", `{.movies[2].ratings[?(@.source=="tomatoes")].value}`, "92"},
+		{"range over filter", `{range .movies[?(@.rank!=1)]}{.rank},{end}`, "2,3,"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := writeJSONPath(&out, templateData, tt.expr); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("writeJSONPath(%s) = %q, want %q", tt.expr, out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteJSONPathErrors(t *testing.T) {
+	for _, expr := range []string{
+		"{.movies[0].title",
+		"{.movies[0}",
+		"{.movies[abc]}",
+		"{movies}",
+		`{.movies[?(@.rank>1)]}`,
+		`{.movies[?(.rank==1)]}`,
+		`{"unterminated}`,
+	} {
+		var out strings.Builder
+		if err := writeJSONPath(&out, templateData, expr); err == nil {
+			t.Errorf("writeJSONPath(%s) = %q, want an error", expr, out.String())
+		}
+	}
+}
+
+func TestWriteGoTemplate(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"field", `{{(index .movies 0).title}}`, "The Matrix"},
+		{"missing key", `[{{(index .movies 0).nope}}]`, "[<no value>]"},
+		{"join", `{{join ", " (index .movies 0).genres}}`, "action, sci-fi"},
+		{"join scalar", `{{join ", " (index .movies 0).title}}`, "The Matrix"},
+		{"default nil", `{{default "n/a" (index .movies 1).tvdb_id}}`, "n/a"},
+		{"default missing", `{{default "n/a" (index .movies 0).tvdb_id}}`, "n/a"},
+		{"default empty list", `{{default "none" .shows}}`, "none"},
+		{"default set", `{{default "n/a" (index .movies 0).imdb_id}}`, "tt0133093"},
+		{"truncate", `{{truncate 10 (index .movies 1).title}}`, "The Matri…"},
+		{"truncate short", `{{truncate 30 (index .movies 0).title}}`, "The Matrix"},
+		{"ratingBySource", `{{ratingBySource (index .movies 2).ratings "imdb"}}`, "8.6"},
+		{"ratingBySource missing", `[{{ratingBySource (index .movies 2).ratings "letterboxd"}}]`, "[<no value>]"},
+		{"ratingBySource without ratings", `[{{ratingBySource (index .movies 0).ratings "imdb"}}]`, "[<no value>]"},
+		{"range", `{{range .movies}}{{.rank}} {{end}}`, "1 2 3 "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := writeGoTemplate(&out, templateData, tt.text); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("%s = %q, want %q", tt.text, out.String(), tt.want)
+			}
+		})
+	}
+
+	var out strings.Builder
+	if err := writeGoTemplate(&out, templateData, "{{.movies"); err == nil {
+		t.Error("expected a parse error")
+	}
+}
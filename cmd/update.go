@@ -4,6 +4,9 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/luckylittle/mdblist-cli/internal/client"
 	"github.com/spf13/cobra"
@@ -18,15 +21,35 @@ var updateListNameCmd = &cobra.Command{
 	Use:   "list-name <new-name>",
 	Short: "Updates the name of a list.",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		newName := args[0]
-		listID, _ := cmd.Flags().GetInt("id")
+		listID := listIDOrDefault(cmd)
 		username, _ := cmd.Flags().GetString("username")
 		listName, _ := cmd.Flags().GetString("listname")
 
 		if listID == 0 && (username == "" || listName == "") {
-			fmt.Println("Error: either --id or both --username and --listname are required")
-			return
+			return usageErrorf("either --id or both --username and --listname are required")
+		}
+
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			var (
+				lists []client.List
+				err   error
+			)
+			if listID != 0 {
+				lists, err = apiClient.GetListByIDContext(cmd.Context(), listID)
+			} else {
+				lists, err = apiClient.GetListByNameContext(cmd.Context(), username, listName)
+			}
+			if err != nil {
+				return err
+			}
+			if len(lists) == 0 {
+				return fmt.Errorf("list not found")
+			}
+			plan := renamePlan{ListID: lists[0].ID, Name: lists[0].Name, NewName: newName, Changed: lists[0].Name != newName}
+			fmt.Fprintln(os.Stderr, "Dry run: nothing was changed.")
+			return printData(plan)
 		}
 
 		var (
@@ -35,83 +58,310 @@ var updateListNameCmd = &cobra.Command{
 		)
 
 		if listID != 0 {
-			response, err = apiClient.UpdateListNameByID(listID, newName)
+			response, err = apiClient.UpdateListNameByIDContext(cmd.Context(), listID, newName)
 		} else {
-			response, err = apiClient.UpdateListNameByName(username, listName, newName)
+			response, err = apiClient.UpdateListNameByNameContext(cmd.Context(), username, listName, newName)
 		}
 
 		if err != nil {
-			fmt.Println("Error:", err)
-			return
+			return err
 		}
 		fmt.Println("List name updated successfully.")
-		printJSON(response)
+		return printJSON(response)
 	},
 }
 
 var updateListItemsCmd = &cobra.Command{
-	Use:   "list-items",
+	Use:   "list-items [id...|-]",
 	Short: "You can modify static list by adding or removing items.",
+	Long: `You can modify static list by adding or removing items.
+
+Besides the --movie-*/--show-* flags, IDs can be given as arguments or read
+with --from-file (or '-' for standard input). The input may hold one IMDb
+(tt...) or TMDb ID per line, optionally followed by its type, CSV or TSV with
+a header naming the ID and type columns, or the json, ndjson, yaml or csv
+output of 'get list-items'. IMDb and TMDb IDs are told apart by their form;
+items without a type use --type.
+
+Large inputs are sent in batches of --batch-size items, --parallel at a time.
+If a batch fails, the error names it together with the --skip value that
+resumes the run from there.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		listID, _ := cmd.Flags().GetInt("id")
+		listID := listIDOrDefault(cmd)
 		if listID == 0 {
-			return errors.New("--id is required")
+			return usageErrorf("--id is required")
 		}
 
 		action, _ := cmd.Flags().GetString("action")
 		if action != "add" && action != "remove" {
-			return errors.New("--action must be either 'add' or 'remove'")
+			return usageErrorf("--action must be either 'add' or 'remove'")
 		}
-
-		movieTmdbIDs, _ := cmd.Flags().GetIntSlice("movie-tmdb")
-		movieImdbIDs, _ := cmd.Flags().GetStringSlice("movie-imdb")
-		showTmdbIDs, _ := cmd.Flags().GetIntSlice("show-tmdb")
-		showImdbIDs, _ := cmd.Flags().GetStringSlice("show-imdb")
-
-		if len(movieTmdbIDs) == 0 && len(movieImdbIDs) == 0 && len(showTmdbIDs) == 0 && len(showImdbIDs) == 0 {
-			return errors.New("at least one movie or show ID must be provided")
+		mediaType, _ := cmd.Flags().GetString("type")
+		if mediaType != "movie" && mediaType != "show" {
+			return usageErrorf("--type must be either 'movie' or 'show'")
+		}
+		batchSize, _ := cmd.Flags().GetInt("batch-size")
+		parallel, _ := cmd.Flags().GetInt("parallel")
+		skip, _ := cmd.Flags().GetInt("skip")
+		if batchSize < 1 || parallel < 1 || skip < 0 {
+			return usageErrorf("--batch-size and --parallel must be at least 1, --skip must not be negative")
 		}
 
-		items := client.ModifyListRequest{}
-		for _, id := range movieTmdbIDs {
-			items.Movies = append(items.Movies, map[string]interface{}{"tmdb": id})
+		items := modifyRequestFromFlags(cmd)
+		fromFile, _ := cmd.Flags().GetString("from-file")
+		entries, err := readMediaEntries(args, fromFile)
+		if err != nil {
+			return err
 		}
-		for _, id := range movieImdbIDs {
-			items.Movies = append(items.Movies, map[string]interface{}{"imdb": id})
+		if err := addMediaEntries(&items, entries, mediaType); err != nil {
+			return err
 		}
-		for _, id := range showTmdbIDs {
-			items.Shows = append(items.Shows, map[string]interface{}{"tmdb": id})
+		if len(items.Movies) == 0 && len(items.Shows) == 0 {
+			return usageErrorf("at least one movie or show ID must be provided")
 		}
-		for _, id := range showImdbIDs {
-			items.Shows = append(items.Shows, map[string]interface{}{"imdb": id})
+		if skip > 0 {
+			if skip >= items.Count() {
+				return usageErrorf("--skip %d leaves nothing to send, the input holds %d items", skip, items.Count())
+			}
+			items = skipItems(items, skip)
+			logVerbose("Skipping the first %d items", skip)
+		}
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			current, err := fetchAllListItems(cmd.Context(), listID)
+			if err != nil {
+				return err
+			}
+			plan := planModification(current, action, items)
+			plan.ListID = listID
+			return printPlan(plan)
+		}
+
+		batches := (items.Count() + batchSize - 1) / batchSize
+		logVerbose("Sending %d movie(s) and %d show(s) in %d batch(es)", len(items.Movies), len(items.Shows), batches)
+
+		if err := checkQuota(cmd.Context(), batches); err != nil {
+			return err
 		}
 
-		response, err := apiClient.ModifyListItems(listID, action, items)
+		response, err := apiClient.ModifyListItemsBatchedContext(cmd.Context(), listID, action, items, client.BatchOptions{
+			Size:        batchSize,
+			Parallelism: parallel,
+			OnBatch: func(index, total int, _ *client.ModifyListItemsResponse) {
+				logVerbose("Batch %d/%d done", index+1, total)
+			},
+		})
+		var batchErr *client.BatchError
+		if errors.As(err, &batchErr) {
+			if batches > 1 {
+				// show what the other batches achieved before failing
+				if err := printData(response); err != nil {
+					return err
+				}
+			}
+			return fmt.Errorf("%w; rerun with --skip %d to resume", err, skip+batchErr.Offset)
+		}
 		if err != nil {
-			return fmt.Errorf("%w", err)
+			return err
 		}
 
 		fmt.Printf("List items updated successfully (action: %s).\n", action)
-		printData(response)
-		return nil
+		return printData(response)
 	},
 }
 
+var updateWatchlistItemsCmd = &cobra.Command{
+	Use:   "watchlist-items",
+	Short: "Add or remove items from your watchlist.",
+}
+
+var updateWatchlistAddCmd = newWatchlistModifyCmd("add", "Add items to your watchlist.")
+var updateWatchlistRemoveCmd = newWatchlistModifyCmd("remove", "Remove items from your watchlist.")
+
+func newWatchlistModifyCmd(action, short string) *cobra.Command {
+	c := &cobra.Command{
+		Use:   action + " [id...|-]",
+		Short: short,
+		Long: short + `
+
+Besides the --movie-*/--show-* flags, IDs can be given as arguments or read
+with --from-file (or '-' for standard input), in any of the formats accepted
+by 'update list-items'. Items without a type use --type.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			items := modifyRequestFromFlags(cmd)
+
+			fromFile, _ := cmd.Flags().GetString("from-file")
+			mediaType, _ := cmd.Flags().GetString("type")
+			if mediaType != "movie" && mediaType != "show" {
+				return usageErrorf("--type must be either 'movie' or 'show'")
+			}
+			entries, err := readMediaEntries(args, fromFile)
+			if err != nil {
+				return err
+			}
+			if err := addMediaEntries(&items, entries, mediaType); err != nil {
+				return err
+			}
+
+			if len(items.Movies) == 0 && len(items.Shows) == 0 {
+				return usageErrorf("at least one movie or show ID must be provided")
+			}
+
+			if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+				current, err := fetchAllWatchlistItems(cmd.Context())
+				if err != nil {
+					return err
+				}
+				return printPlan(planModification(current, action, items))
+			}
+
+			response, err := apiClient.ModifyWatchlistContext(cmd.Context(), action, items)
+			if err != nil {
+				return err
+			}
+
+			if cmd.Flags().Changed("output") {
+				return printData(response)
+			}
+			printWatchlistSummary(action, response)
+			return nil
+		},
+	}
+	addMediaIDFlags(c, "add/remove")
+	c.Flags().StringP("from-file", "f", "", "Read IDs from a file of ID lines, CSV/TSV or get list-items output ('-' for stdin)")
+	c.Flags().String("type", "movie", "Media type of IDs whose type is not given in the input: 'movie' or 'show'")
+	c.Flags().Bool("dry-run", false, "Print what would change, compared with the current watchlist, without changing it")
+	return c
+}
+
+// addMediaIDFlags registers the --movie-*/--show-* flags shared by the
+// commands that modify lists and the watchlist.
+func addMediaIDFlags(c *cobra.Command, verb string) {
+	c.Flags().IntSlice("movie-tmdb", []int{}, "TMDb ID of a movie to "+verb)
+	c.Flags().StringSlice("movie-imdb", []string{}, "IMDb ID of a movie to "+verb)
+	c.Flags().IntSlice("show-tmdb", []int{}, "TMDb ID of a show to "+verb)
+	c.Flags().StringSlice("show-imdb", []string{}, "IMDb ID of a show to "+verb)
+}
+
+func modifyRequestFromFlags(cmd *cobra.Command) client.ModifyListRequest {
+	movieTmdbIDs, _ := cmd.Flags().GetIntSlice("movie-tmdb")
+	movieImdbIDs, _ := cmd.Flags().GetStringSlice("movie-imdb")
+	showTmdbIDs, _ := cmd.Flags().GetIntSlice("show-tmdb")
+	showImdbIDs, _ := cmd.Flags().GetStringSlice("show-imdb")
+
+	items := client.ModifyListRequest{}
+	for _, id := range movieTmdbIDs {
+		items.Movies = append(items.Movies, map[string]interface{}{"tmdb": id})
+	}
+	for _, id := range movieImdbIDs {
+		items.Movies = append(items.Movies, map[string]interface{}{"imdb": id})
+	}
+	for _, id := range showTmdbIDs {
+		items.Shows = append(items.Shows, map[string]interface{}{"tmdb": id})
+	}
+	for _, id := range showImdbIDs {
+		items.Shows = append(items.Shows, map[string]interface{}{"imdb": id})
+	}
+	return items
+}
+
+// skipItems drops the first n items of the request, counting movies first,
+// in the order in which they are sent.
+func skipItems(items client.ModifyListRequest, n int) client.ModifyListRequest {
+	if n >= len(items.Movies) {
+		n -= len(items.Movies)
+		items.Movies = nil
+		if n > len(items.Shows) {
+			n = len(items.Shows)
+		}
+		items.Shows = items.Shows[n:]
+		return items
+	}
+	items.Movies = items.Movies[n:]
+	return items
+}
+
+// addMediaEntries appends entries to the request, deciding movie or show by
+// the type given in the input, or defaultType. Duplicates are dropped.
+func addMediaEntries(items *client.ModifyListRequest, entries []mediaEntry, defaultType string) error {
+	seen := map[string]bool{}
+	for _, e := range entries {
+		ref, err := mediaRef(e.id)
+		if err != nil {
+			return err
+		}
+		mediaType, err := normalizeMediaType(e.mediaType, defaultType)
+		if err != nil {
+			return err
+		}
+		key := mediaType + ":" + e.id
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if mediaType == "show" {
+			items.Shows = append(items.Shows, ref)
+		} else {
+			items.Movies = append(items.Movies, ref)
+		}
+	}
+	return nil
+}
+
+func normalizeMediaType(t, defaultType string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(t)) {
+	case "":
+		return defaultType, nil
+	case "movie", "movies":
+		return "movie", nil
+	case "show", "shows", "tv", "series":
+		return "show", nil
+	}
+	return "", usageErrorf("invalid media type %q: must be either 'movie' or 'show'", t)
+}
+
+// mediaRef turns an IMDb ("tt...") or numeric TMDb ID into a request item.
+func mediaRef(id string) (map[string]interface{}, error) {
+	if strings.HasPrefix(id, "tt") {
+		return map[string]interface{}{"imdb": id}, nil
+	}
+	tmdb, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, usageErrorf("invalid ID %q: expected an IMDb (tt...) or numeric TMDb ID", id)
+	}
+	return map[string]interface{}{"tmdb": tmdb}, nil
+}
+
+func printWatchlistSummary(action string, r *client.ModifyWatchlistResponse) {
+	if action == "add" {
+		fmt.Printf("Added to watchlist: %d movie(s), %d show(s)\n", r.Added.Movies, r.Added.Shows)
+		fmt.Printf("Already on watchlist: %d movie(s), %d show(s)\n", r.Existing.Movies, r.Existing.Shows)
+	} else {
+		fmt.Println("Watchlist items removed.")
+	}
+	fmt.Printf("Not found: %d movie(s), %d show(s)\n", r.NotFound.Movies, r.NotFound.Shows)
+}
+
 func init() {
 	rootCmd.AddCommand(updateCmd)
 	updateCmd.AddCommand(updateListNameCmd)
 	updateCmd.AddCommand(updateListItemsCmd)
+	updateCmd.AddCommand(updateWatchlistItemsCmd)
+	updateWatchlistItemsCmd.AddCommand(updateWatchlistAddCmd)
+	updateWatchlistItemsCmd.AddCommand(updateWatchlistRemoveCmd)
 
 	updateListNameCmd.Flags().Int("id", 0, "List ID")
 	updateListNameCmd.Flags().String("username", "", "Username of the list owner")
 	updateListNameCmd.Flags().String("listname", "", "Current name/slug of the list")
+	updateListNameCmd.Flags().Bool("dry-run", false, "Print the current and new name without renaming the list")
 
-	updateListItemsCmd.Flags().IntP("id", "i", 0, "List ID (required)")
+	updateListItemsCmd.Flags().IntP("id", "i", 0, "List ID (required unless the profile sets list_id)")
 	updateListItemsCmd.Flags().StringP("action", "a", "", "Action to perform: 'add' or 'remove' (required)")
-	updateListItemsCmd.Flags().IntSlice("movie-tmdb", []int{}, "TMDb ID of a movie to add/remove")
-	updateListItemsCmd.Flags().StringSlice("movie-imdb", []string{}, "IMDb ID of a movie to add/remove")
-	updateListItemsCmd.Flags().IntSlice("show-tmdb", []int{}, "TMDb ID of a show to add/remove")
-	updateListItemsCmd.Flags().StringSlice("show-imdb", []string{}, "IMDb ID of a show to add/remove")
-	updateListItemsCmd.MarkFlagRequired("id")
+	addMediaIDFlags(updateListItemsCmd, "add/remove")
+	updateListItemsCmd.Flags().StringP("from-file", "f", "", "Read IDs from a file of ID lines, CSV/TSV or get list-items output ('-' for stdin)")
+	updateListItemsCmd.Flags().String("type", "movie", "Media type of IDs whose type is not given in the input: 'movie' or 'show'")
+	updateListItemsCmd.Flags().Int("batch-size", client.DefaultModifyBatchSize, "Maximum number of items sent per request")
+	updateListItemsCmd.Flags().Int("parallel", 1, "Number of batches sent at the same time")
+	updateListItemsCmd.Flags().Bool("dry-run", false, "Print what would be added, already present or removed, compared with the current list, without changing it")
+	updateListItemsCmd.Flags().Int("skip", 0, "Skip this many items of the input (movies first), to resume after a failed batch")
 	updateListItemsCmd.MarkFlagRequired("action")
 }
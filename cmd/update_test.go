@@ -0,0 +1,191 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package cmd
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+	"github.com/luckylittle/mdblist-cli/internal/mdblisttest"
+)
+
+func TestUpdateListName(t *testing.T) {
+	newServer(t)
+
+	var plan renamePlan
+	decode(t, mustRun(t, "update", "list-name", "Classics", "--id", "1", "--dry-run"), &plan)
+	if plan.Name != "Faves" || plan.NewName != "Classics" || !plan.Changed {
+		t.Errorf("dry run plan = %+v", plan)
+	}
+
+	r := mustRun(t, "update", "list-name", "Classics", "--username", "bob", "--listname", "faves")
+	if !strings.HasPrefix(r.stdout, "List name updated successfully.") {
+		t.Errorf("output =\n%s", r.stdout)
+	}
+	var lists []client.List
+	decode(t, mustRun(t, "get", "list", "--id", "1"), &lists)
+	if len(lists) != 1 || lists[0].Name != "Classics" {
+		t.Errorf("list after rename = %+v", lists)
+	}
+
+	if r := execute(t, "", "update", "list-name", "Classics"); r.code != exitUsage {
+		t.Errorf("list-name without a list: exit code = %d", r.code)
+	}
+}
+
+func TestUpdateListItems(t *testing.T) {
+	srv := newServer(t)
+
+	r := mustRun(t, "update", "list-items", "--id", "1", "-a", "add", mdblisttest.MatrixReloadedIMDb, "--movie-tmdb", "603")
+	if !strings.Contains(r.stdout, "List items updated successfully (action: add).") {
+		t.Errorf("output =\n%s", r.stdout)
+	}
+	if items := srv.ListItems(1); len(items.Movies) != 2 {
+		t.Errorf("list holds %d movies after add, want 2", len(items.Movies))
+	}
+
+	// the output of get list-items can be piped back in
+	export := mustRun(t, "get", "list-items", "--id", "1", "-o", "csv", "--columns", "imdb_id,mediatype")
+	r = execute(t, export.stdout, "update", "list-items", "--id", "1", "-a", "remove", "--dry-run", "-")
+	var plan itemsPlan
+	decode(t, r, &plan)
+	if plan.Remove != 3 || !strings.Contains(r.stderr, "Nothing was changed.") {
+		t.Errorf("dry run plan = %+v, stderr:\n%s", plan, r.stderr)
+	}
+	if items := srv.ListItems(1); len(items.Movies)+len(items.Shows) != 3 {
+		t.Errorf("dry run changed the list: %+v", items)
+	}
+
+	ids := writeFile(t, "ids.txt", mdblisttest.MatrixIMDb+"\n"+mdblisttest.MatrixReloadedIMDb+"\n")
+	mustRun(t, "update", "list-items", "--id", "1", "-a", "remove", "-f", ids, "--batch-size", "1", "--parallel", "2")
+	if items := srv.ListItems(1); len(items.Movies) != 0 || len(items.Shows) != 1 {
+		t.Errorf("list after remove = %+v", items)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"bad action", []string{"-a", "move", "603"}, exitUsage},
+		{"bad type", []string{"-a", "add", "--type", "episode", "603"}, exitUsage},
+		{"no IDs", []string{"-a", "add"}, exitUsage},
+		{"dynamic list", []string{"--id", "2", "-a", "add", "603"}, exitError},
+		{"unknown list", []string{"--id", "999", "-a", "add", "603"}, exitNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"update", "list-items", "--id", "1"}, tt.args...)
+			if r := execute(t, "", args...); r.code != tt.code {
+				t.Errorf("exit code = %d, want %d; stderr:\n%s", r.code, tt.code, r.stderr)
+			}
+		})
+	}
+}
+
+func TestUpdateListItemsFailedBatch(t *testing.T) {
+	srv := newServer(t)
+	ids := writeFile(t, "ids.txt", mdblisttest.MatrixReloadedIMDb+"\n"+mdblisttest.DarkIMDb+"\n")
+
+	// the first batch goes through, the second fails
+	srv.FailAfter(1, http.StatusBadGateway, "Bad gateway")
+	r := execute(t, "", "update", "list-items", "--id", "1", "-a", "add", "-f", ids, "--batch-size", "1", "--retries", "0")
+	if r.code != exitServer || !strings.Contains(r.stderr, "--skip 1") {
+		t.Errorf("exit code = %d, stderr:\n%s", r.code, r.stderr)
+	}
+}
+
+func TestUpdateWatchlistItems(t *testing.T) {
+	newServer(t)
+
+	r := mustRun(t, "update", "watchlist-items", "add", "--movie-imdb", mdblisttest.MatrixIMDb, "--show-tmdb", "70523")
+	if !strings.Contains(r.stdout, "Added to watchlist: 1 movie(s), 1 show(s)") {
+		t.Errorf("add output =\n%s", r.stdout)
+	}
+
+	var plan itemsPlan
+	decode(t, mustRun(t, "update", "watchlist-items", "remove", "--movie-imdb", mdblisttest.MatrixReloadedIMDb, "--dry-run"), &plan)
+	if plan.Remove != 1 {
+		t.Errorf("dry run plan = %+v", plan)
+	}
+
+	ids := writeFile(t, "ids.txt", mdblisttest.MatrixReloadedIMDb+"\n")
+	var resp client.ModifyWatchlistResponse
+	decode(t, mustRun(t, "update", "watchlist-items", "remove", "-f", ids, "-o", "json"), &resp)
+	if resp.NotFound.Movies != 0 {
+		t.Errorf("remove response = %+v", resp)
+	}
+
+	var watchlist client.WatchlistItems
+	decode(t, mustRun(t, "get", "watchlist-items"), &watchlist)
+	if len(watchlist.Movies) != 1 || watchlist.Movies[0].ImdbID != mdblisttest.MatrixIMDb || len(watchlist.Shows) != 1 {
+		t.Errorf("watchlist = %+v", watchlist)
+	}
+
+	// IDs can also be given as arguments or on stdin
+	r = mustRun(t, "update", "watchlist-items", "add", mdblisttest.MatrixReloadedIMDb)
+	if !strings.Contains(r.stdout, "Added to watchlist: 1 movie(s), 0 show(s)") {
+		t.Errorf("add with an argument =\n%s", r.stdout)
+	}
+	r = execute(t, mdblisttest.MatrixReloadedIMDb+"\n70523 show\n", "update", "watchlist-items", "remove", "-")
+	if r.code != exitOK || !strings.Contains(r.stdout, "Not found: 0 movie(s), 0 show(s)") {
+		t.Errorf("remove from stdin: exit code %d, output:\n%s%s", r.code, r.stdout, r.stderr)
+	}
+	decode(t, mustRun(t, "get", "watchlist-items"), &watchlist)
+	if len(watchlist.Movies) != 1 || len(watchlist.Shows) != 0 {
+		t.Errorf("watchlist = %+v", watchlist)
+	}
+
+	if r := execute(t, "", "update", "watchlist-items", "add"); r.code != exitUsage {
+		t.Errorf("add without IDs: exit code = %d", r.code)
+	}
+}
+
+func TestQuotaCheck(t *testing.T) {
+	srv := newServer(t)
+	add := []string{"update", "list-items", "--id", "1", "-a", "add", mdblisttest.MatrixReloadedIMDb, "--retries", "0"}
+
+	tests := []struct {
+		name     string
+		setup    func()
+		args     []string
+		code     int
+		requests int
+	}{
+		{name: "off", args: []string{"-v"}, code: exitOK, requests: 1},
+		{name: "warn", args: []string{"--quota-check", "warn"}, code: exitOK, requests: 2},
+		{
+			name:  "warn when the check fails",
+			setup: func() { srv.FailNext(1, http.StatusTooManyRequests, "API Limit Reached!") },
+			args:  []string{"--quota-check", "warn"}, code: exitOK, requests: 2,
+		},
+		{
+			name:  "refuse when the check fails",
+			setup: func() { srv.FailNext(1, http.StatusTooManyRequests, "API Limit Reached!") },
+			args:  []string{"--quota-check", "refuse"}, code: exitRateLimited, requests: 1,
+		},
+		{name: "invalid", args: []string{"--quota-check", "maybe"}, code: exitUsage, requests: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup()
+			}
+			before := srv.Requests()
+			r := execute(t, "", append(add, tt.args...)...)
+			if r.code != tt.code {
+				t.Errorf("exit code = %d, want %d; stderr:\n%s", r.code, tt.code, r.stderr)
+			}
+			if n := srv.Requests() - before; n != tt.requests {
+				t.Errorf("sent %d requests, want %d", n, tt.requests)
+			}
+		})
+	}
+
+	// the value is checked before the API key
+	t.Setenv("MDBLIST_API_KEY", "")
+	if r := execute(t, "", append(add, "--quota-check", "maybe")...); r.code != exitUsage {
+		t.Errorf("without a key: exit code = %d, want %d; stderr:\n%s", r.code, exitUsage, r.stderr)
+	}
+}
@@ -0,0 +1,172 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// DefaultModifyBatchSize is the number of items sent per request by
+// ModifyListItemsBatchedContext when BatchOptions.Size is not set.
+const DefaultModifyBatchSize = 200
+
+// BatchOptions controls how a large list modification is split up.
+type BatchOptions struct {
+	// Size is the maximum number of items per request.
+	Size int
+	// Parallelism is the number of batches sent at once. Values below 2
+	// send the batches one after another.
+	Parallelism int
+	// OnBatch, if set, is called after each successful batch. Calls are
+	// serialized but may arrive out of order when Parallelism > 1.
+	OnBatch func(index, total int, resp *ModifyListItemsResponse)
+}
+
+// BatchError reports the first batch of a modification that failed. Items
+// are numbered movies first, then shows, in request order, so a rerun can
+// resume by skipping Offset items.
+type BatchError struct {
+	// Index is the 0-based number of the failed batch, out of Total.
+	Index int
+	Total int
+	// Offset is the position of the first item of the failed batch.
+	Offset int
+	// Size is the number of items in the failed batch.
+	Size int
+	Err  error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch %d/%d (items %d-%d) failed: %v", e.Index+1, e.Total, e.Offset+1, e.Offset+e.Size, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Count returns the number of items in the request.
+func (r ModifyListRequest) Count() int {
+	return len(r.Movies) + len(r.Shows)
+}
+
+// Batches splits the request into requests of at most size items, movies
+// first, then shows.
+func (r ModifyListRequest) Batches(size int) []ModifyListRequest {
+	if size <= 0 {
+		size = DefaultModifyBatchSize
+	}
+	var batches []ModifyListRequest
+	movies, shows := r.Movies, r.Shows
+	for len(movies)+len(shows) > 0 {
+		var b ModifyListRequest
+		n := size
+		if n > len(movies) {
+			n = len(movies)
+		}
+		b.Movies, movies = movies[:n], movies[n:]
+		n = size - n
+		if n > len(shows) {
+			n = len(shows)
+		}
+		b.Shows, shows = shows[:n], shows[n:]
+		batches = append(batches, b)
+	}
+	return batches
+}
+
+// merge adds the counters of o to r.
+func (r *ModifyListItemsResponse) merge(o *ModifyListItemsResponse) {
+	add := func(dst *map[string]int, src map[string]int) {
+		if *dst == nil {
+			*dst = map[string]int{}
+		}
+		for k, v := range src {
+			(*dst)[k] += v
+		}
+	}
+	add(&r.Added, o.Added)
+	add(&r.Existing, o.Existing)
+	add(&r.NotFound, o.NotFound)
+}
+
+func (c *Client) ModifyListItemsBatched(listID int, action string, items ModifyListRequest, opts BatchOptions) (*ModifyListItemsResponse, error) {
+	return c.ModifyListItemsBatchedContext(context.Background(), listID, action, items, opts)
+}
+
+// ModifyListItemsBatchedContext sends a list modification in batches and
+// adds up the counters of the batches that succeeded. After a failure no new
+// batches are started; the error is a *BatchError for the earliest failed
+// batch, and the returned response covers every batch that succeeded.
+func (c *Client) ModifyListItemsBatchedContext(ctx context.Context, listID int, action string, items ModifyListRequest, opts BatchOptions) (*ModifyListItemsResponse, error) {
+	size := opts.Size
+	if size <= 0 {
+		size = DefaultModifyBatchSize
+	}
+	batches := items.Batches(size)
+	workers := opts.Parallelism
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(batches) {
+		workers = len(batches)
+	}
+
+	var (
+		mu       sync.Mutex
+		total    = &ModifyListItemsResponse{Added: map[string]int{}, Existing: map[string]int{}, NotFound: map[string]int{}}
+		firstErr *BatchError
+		stop     = make(chan struct{})
+		stopOnce sync.Once
+		next     = make(chan int)
+		wg       sync.WaitGroup
+	)
+	fail := func(i int, err error) {
+		if firstErr == nil || i < firstErr.Index {
+			firstErr = &BatchError{Index: i, Total: len(batches), Offset: i * size, Size: batches[i].Count(), Err: err}
+		}
+		// batches already in flight are left to finish
+		stopOnce.Do(func() { close(stop) })
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				resp, err := c.ModifyListItemsContext(ctx, listID, action, batches[i])
+				mu.Lock()
+				if err != nil {
+					fail(i, err)
+				} else {
+					total.merge(resp)
+					if opts.OnBatch != nil {
+						opts.OnBatch(i, len(batches), resp)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for i := range batches {
+		select {
+		case next <- i:
+		case <-stop:
+			break feed
+		case <-ctx.Done():
+			mu.Lock()
+			fail(i, ctx.Err())
+			mu.Unlock()
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if firstErr != nil {
+		return total, firstErr
+	}
+	return total, nil
+}
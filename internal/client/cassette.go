@@ -0,0 +1,239 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Interaction is a single recorded HTTP exchange, as stored on disk by the
+// recorder. The API key is never part of it.
+type Interaction struct {
+	Request struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+		Body   string `json:"body,omitempty"`
+	} `json:"request"`
+	Response struct {
+		StatusCode int         `json:"status_code"`
+		Header     http.Header `json:"header,omitempty"`
+		Body       string      `json:"body"`
+	} `json:"response"`
+}
+
+// WithRecorder stores every HTTP exchange made by the client as a numbered
+// JSON file in dir, with the API key stripped.
+func WithRecorder(dir string) Option {
+	return func(c *Client) {
+		next := c.httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		hc := *c.httpClient
+		hc.Transport = &recorder{dir: dir, next: next, redact: c.Redact}
+		c.httpClient = &hc
+	}
+}
+
+// WithReplay serves responses from a directory written by WithRecorder
+// instead of the network. Requests are matched on method, URL (without the
+// API key) and body; identical requests are answered in recorded order.
+func WithReplay(dir string) Option {
+	return func(c *Client) {
+		hc := *c.httpClient
+		hc.Transport = &replayer{dir: dir, redact: c.Redact}
+		c.httpClient = &hc
+	}
+}
+
+// canonicalURL drops the apikey parameter and sorts the query so recordings
+// do not depend on the key or on parameter order.
+func canonicalURL(req *http.Request) string {
+	u := *req.URL
+	q := u.Query()
+	q.Del("apikey")
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
+
+func readRequestBody(req *http.Request) (string, error) {
+	if req.Body == nil {
+		return "", nil
+	}
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	return string(b), nil
+}
+
+type recorder struct {
+	dir    string
+	next   http.RoundTripper
+	redact func(string) string
+
+	mu sync.Mutex
+	n  int
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	var in Interaction
+	in.Request.Method = req.Method
+	in.Request.URL = canonicalURL(req)
+	in.Request.Body = r.redact(reqBody)
+	in.Response.StatusCode = resp.StatusCode
+	in.Response.Header = http.Header{}
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			in.Response.Header.Add(k, r.redact(v))
+		}
+	}
+	in.Response.Body = r.redact(string(respBody))
+
+	if err := r.save(&in); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *recorder) save(in *Interaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.n == 0 {
+		if err := os.MkdirAll(r.dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create cassette directory: %w", err)
+		}
+		existing, err := cassetteFiles(r.dir)
+		if err != nil {
+			return err
+		}
+		r.n = len(existing)
+	}
+	r.n++
+
+	b, err := json.MarshalIndent(in, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode interaction: %w", err)
+	}
+	name := filepath.Join(r.dir, fmt.Sprintf("%04d.json", r.n))
+	if err := os.WriteFile(name, b, 0o644); err != nil {
+		return fmt.Errorf("failed to write interaction: %w", err)
+	}
+	return nil
+}
+
+type replayer struct {
+	dir    string
+	redact func(string) string
+
+	once    sync.Once
+	loadErr error
+	mu      sync.Mutex
+	queue   map[string][]*Interaction
+}
+
+func replayKey(method, url, body string) string {
+	return method + " " + url + "\n" + body
+}
+
+func (r *replayer) load() {
+	if _, err := os.Stat(r.dir); err != nil {
+		r.loadErr = fmt.Errorf("failed to open cassette directory: %w", err)
+		return
+	}
+	files, err := cassetteFiles(r.dir)
+	if err != nil {
+		r.loadErr = err
+		return
+	}
+	r.queue = map[string][]*Interaction{}
+	for _, name := range files {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			r.loadErr = fmt.Errorf("failed to read interaction: %w", err)
+			return
+		}
+		in := &Interaction{}
+		if err := json.Unmarshal(b, in); err != nil {
+			r.loadErr = fmt.Errorf("failed to decode interaction %s: %w", filepath.Base(name), err)
+			return
+		}
+		key := replayKey(in.Request.Method, in.Request.URL, in.Request.Body)
+		r.queue[key] = append(r.queue[key], in)
+	}
+}
+
+func (r *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.once.Do(r.load)
+	if r.loadErr != nil {
+		return nil, r.loadErr
+	}
+
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	key := replayKey(req.Method, canonicalURL(req), r.redact(body))
+
+	r.mu.Lock()
+	queue := r.queue[key]
+	if len(queue) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("no recorded response for %s %s", req.Method, canonicalURL(req))
+	}
+	in := queue[0]
+	r.queue[key] = queue[1:]
+	r.mu.Unlock()
+
+	header := http.Header{}
+	for k, vs := range in.Response.Header {
+		header[k] = append([]string(nil), vs...)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+		StatusCode:    in.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(in.Response.Body)),
+		ContentLength: int64(len(in.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+func cassetteFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cassette directory: %w", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,93 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Sentinel errors for the common failure classes. They match both API
+// responses and client-side checks:
+//
+//	if errors.Is(err, client.ErrNotFound) {
+//		...
+//	}
+var (
+	ErrUnauthorized  = errors.New("unauthorized")
+	ErrNotFound      = errors.New("not found")
+	ErrRateLimited   = errors.New("rate limited")
+	ErrQuotaExceeded = errors.New("daily API quota exceeded")
+	// ErrInvalidBaseURL is returned when the base URL is not an absolute
+	// http or https URL.
+	ErrInvalidBaseURL = errors.New("invalid base URL")
+)
+
+// APIError represents an error response from the MDBList API.
+type APIError struct {
+	StatusCode int
+	// Message is the error text reported by MDBList, or the raw response
+	// body when it is not an MDBList error object.
+	Message string
+	// Body is the raw response body.
+	Body string
+	// RetryAfter is the delay requested by a Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error (status %d): %s", e.StatusCode, e.Message)
+}
+
+// Is reports whether the error belongs to the class of a sentinel error.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrQuotaExceeded:
+		// MDBList answers "API Limit Reached!" once the daily quota is gone
+		return e.StatusCode == http.StatusTooManyRequests &&
+			strings.Contains(strings.ToLower(e.Message), "limit reached")
+	}
+	return false
+}
+
+// Is makes a QuotaError match ErrQuotaExceeded.
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// newAPIError builds an APIError from a response whose body has already
+// been read and redacted. MDBList reports errors as {"error": "..."}, some
+// endpoints use "detail" or "message" instead.
+func newAPIError(resp *http.Response, body string) *APIError {
+	e := &APIError{StatusCode: resp.StatusCode, Message: body, Body: body}
+
+	var payload struct {
+		Error   interface{} `json:"error"`
+		Detail  interface{} `json:"detail"`
+		Message interface{} `json:"message"`
+	}
+	if json.Unmarshal([]byte(body), &payload) == nil {
+		for _, field := range []interface{}{payload.Error, payload.Detail, payload.Message} {
+			if msg, ok := field.(string); ok && msg != "" {
+				e.Message = msg
+				break
+			}
+		}
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+		e.RetryAfter = d
+	}
+	return e
+}
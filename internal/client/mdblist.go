@@ -3,6 +3,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,171 +12,283 @@ import (
 )
 
 const (
-	apiBaseURL = "https://api.mdblist.com"
+	DefaultBaseURL   = "https://api.mdblist.com"
+	DefaultUserAgent = "mdblist-cli"
 )
 
 type Client struct {
 	apiKey     string
+	baseURL    string
+	userAgent  string
 	httpClient *http.Client
+	retry      RetryPolicy
+	limiter    *rateLimiter
 }
 
-func New(apiKey string) (*Client, error) {
+func New(apiKey string, opts ...Option) (*Client, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("MDBList API key is required")
 	}
-	return &Client{
+	c := &Client{
 		apiKey:     apiKey,
+		baseURL:    DefaultBaseURL,
+		userAgent:  DefaultUserAgent,
 		httpClient: &http.Client{},
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
 }
 
 func (c *Client) GetMyLimits() (*MyLimits, error) {
+	return c.GetMyLimitsContext(context.Background())
+}
+
+func (c *Client) GetMyLimitsContext(ctx context.Context) (*MyLimits, error) {
 	var limits MyLimits
-	err := c.doRequest(http.MethodGet, "/user", nil, nil, &limits)
+	err := c.doRequest(ctx, http.MethodGet, "/user", nil, nil, &limits)
 	return &limits, err
 }
 
 func (c *Client) GetMyLists() ([]List, error) {
+	return c.GetMyListsContext(context.Background())
+}
+
+func (c *Client) GetMyListsContext(ctx context.Context) ([]List, error) {
 	var lists []List
-	err := c.doRequest(http.MethodGet, "/lists/user", nil, nil, &lists)
+	err := c.doRequest(ctx, http.MethodGet, "/lists/user", nil, nil, &lists)
 	return lists, err
 }
 
 func (c *Client) GetUserListsByID(userID int) ([]List, error) {
+	return c.GetUserListsByIDContext(context.Background(), userID)
+}
+
+func (c *Client) GetUserListsByIDContext(ctx context.Context, userID int) ([]List, error) {
 	endpoint := fmt.Sprintf("/lists/user/%d", userID)
 	var lists []List
-	err := c.doRequest(http.MethodGet, endpoint, nil, nil, &lists)
+	err := c.doRequest(ctx, http.MethodGet, endpoint, nil, nil, &lists)
 	return lists, err
 }
 
 func (c *Client) GetUserListsByName(username string) ([]List, error) {
+	return c.GetUserListsByNameContext(context.Background(), username)
+}
+
+func (c *Client) GetUserListsByNameContext(ctx context.Context, username string) ([]List, error) {
 	endpoint := fmt.Sprintf("/lists/user/%s", username)
 	var lists []List
-	err := c.doRequest(http.MethodGet, endpoint, nil, nil, &lists)
+	err := c.doRequest(ctx, http.MethodGet, endpoint, nil, nil, &lists)
 	return lists, err
 }
 
 func (c *Client) GetListByID(listID int) ([]List, error) {
+	return c.GetListByIDContext(context.Background(), listID)
+}
+
+func (c *Client) GetListByIDContext(ctx context.Context, listID int) ([]List, error) {
 	endpoint := fmt.Sprintf("/lists/%d", listID)
 	var list []List
-	err := c.doRequest(http.MethodGet, endpoint, nil, nil, &list)
+	err := c.doRequest(ctx, http.MethodGet, endpoint, nil, nil, &list)
 	return list, err
 }
 
 func (c *Client) UpdateListNameByID(listID int, newName string) (*ListUpdateResponse, error) {
+	return c.UpdateListNameByIDContext(context.Background(), listID, newName)
+}
+
+func (c *Client) UpdateListNameByIDContext(ctx context.Context, listID int, newName string) (*ListUpdateResponse, error) {
 	endpoint := fmt.Sprintf("/lists/%d", listID)
 	payload := map[string]string{"name": newName}
 	var response ListUpdateResponse
-	err := c.doRequest(http.MethodPut, endpoint, nil, payload, &response)
+	err := c.doRequest(ctx, http.MethodPut, endpoint, nil, payload, &response)
 	return &response, err
 }
 
 func (c *Client) GetListByName(username, listname string) ([]List, error) {
+	return c.GetListByNameContext(context.Background(), username, listname)
+}
+
+func (c *Client) GetListByNameContext(ctx context.Context, username, listname string) ([]List, error) {
 	endpoint := fmt.Sprintf("/lists/%s/%s", username, listname)
 	var lists []List
-	err := c.doRequest(http.MethodGet, endpoint, nil, nil, &lists)
+	err := c.doRequest(ctx, http.MethodGet, endpoint, nil, nil, &lists)
 	return lists, err
 }
 
 func (c *Client) UpdateListNameByName(username, listname, newName string) (*ListUpdateResponse, error) {
+	return c.UpdateListNameByNameContext(context.Background(), username, listname, newName)
+}
+
+func (c *Client) UpdateListNameByNameContext(ctx context.Context, username, listname, newName string) (*ListUpdateResponse, error) {
 	endpoint := fmt.Sprintf("/lists/%s/%s", username, listname)
 	payload := map[string]string{"name": newName}
 	var response ListUpdateResponse
-	err := c.doRequest(http.MethodPut, endpoint, nil, payload, &response)
+	err := c.doRequest(ctx, http.MethodPut, endpoint, nil, payload, &response)
 	return &response, err
 }
 
 func (c *Client) GetListItems(listID int, params url.Values) (*ListItems, error) {
+	return c.GetListItemsContext(context.Background(), listID, params)
+}
+
+func (c *Client) GetListItemsContext(ctx context.Context, listID int, params url.Values) (*ListItems, error) {
 	endpoint := fmt.Sprintf("/lists/%d/items", listID)
 	var items ListItems
-	err := c.doRequest(http.MethodGet, endpoint, params, nil, &items)
+	err := c.doRequest(ctx, http.MethodGet, endpoint, params, nil, &items)
 	return &items, err
 }
 
 func (c *Client) GetListItemsByName(username, listname string, params url.Values) (*ListItems, error) {
+	return c.GetListItemsByNameContext(context.Background(), username, listname, params)
+}
+
+func (c *Client) GetListItemsByNameContext(ctx context.Context, username, listname string, params url.Values) (*ListItems, error) {
 	endpoint := fmt.Sprintf("/lists/%s/%s/items", username, listname)
 	var items ListItems
-	err := c.doRequest(http.MethodGet, endpoint, params, nil, &items)
+	err := c.doRequest(ctx, http.MethodGet, endpoint, params, nil, &items)
 	return &items, err
 }
 
 func (c *Client) GetListChanges(listID int) (*ListChanges, error) {
+	return c.GetListChangesContext(context.Background(), listID)
+}
+
+func (c *Client) GetListChangesContext(ctx context.Context, listID int) (*ListChanges, error) {
 	endpoint := fmt.Sprintf("/lists/%d/changes", listID)
 	var changes ListChanges
-	err := c.doRequest(http.MethodGet, endpoint, nil, nil, &changes)
+	err := c.doRequest(ctx, http.MethodGet, endpoint, nil, nil, &changes)
 	return &changes, err
 }
 
 func (c *Client) GetMediaInfo(provider, mediaType, mediaID string, params url.Values) (*MediaInfo, error) {
+	return c.GetMediaInfoContext(context.Background(), provider, mediaType, mediaID, params)
+}
+
+func (c *Client) GetMediaInfoContext(ctx context.Context, provider, mediaType, mediaID string, params url.Values) (*MediaInfo, error) {
 	endpoint := fmt.Sprintf("/%s/%s/%s", provider, mediaType, mediaID)
 	var info MediaInfo
-	err := c.doRequest(http.MethodGet, endpoint, params, nil, &info)
+	err := c.doRequest(ctx, http.MethodGet, endpoint, params, nil, &info)
 	return &info, err
 }
 
 func (c *Client) GetMediaInfoBatch(provider, mediaType string, body MediaInfoBatchRequest) ([]MediaInfo, error) {
+	return c.GetMediaInfoBatchContext(context.Background(), provider, mediaType, body)
+}
+
+func (c *Client) GetMediaInfoBatchContext(ctx context.Context, provider, mediaType string, body MediaInfoBatchRequest) ([]MediaInfo, error) {
 	endpoint := fmt.Sprintf("/%s/%s", provider, mediaType)
 	var info []MediaInfo
-	err := c.doRequest(http.MethodPost, endpoint, nil, body, &info)
+	err := c.doRequest(ctx, http.MethodPost, endpoint, nil, body, &info)
 	return info, err
 }
 
 func (c *Client) SearchMedia(mediaType string, params url.Values) (*SearchResult, error) {
+	return c.SearchMediaContext(context.Background(), mediaType, params)
+}
+
+func (c *Client) SearchMediaContext(ctx context.Context, mediaType string, params url.Values) (*SearchResult, error) {
 	endpoint := fmt.Sprintf("/search/%s", mediaType)
 	var result SearchResult
-	err := c.doRequest(http.MethodGet, endpoint, params, nil, &result)
+	err := c.doRequest(ctx, http.MethodGet, endpoint, params, nil, &result)
 	return &result, err
 }
 
 func (c *Client) GetTopLists() ([]List, error) {
+	return c.GetTopListsContext(context.Background())
+}
+
+func (c *Client) GetTopListsContext(ctx context.Context) ([]List, error) {
 	var lists []List
-	err := c.doRequest(http.MethodGet, "/lists/top", nil, nil, &lists)
+	err := c.doRequest(ctx, http.MethodGet, "/lists/top", nil, nil, &lists)
 	return lists, err
 }
 
 func (c *Client) SearchLists(params url.Values) ([]List, error) {
+	return c.SearchListsContext(context.Background(), params)
+}
+
+func (c *Client) SearchListsContext(ctx context.Context, params url.Values) ([]List, error) {
 	var lists []List
-	err := c.doRequest(http.MethodGet, "/lists/search", params, nil, &lists)
+	err := c.doRequest(ctx, http.MethodGet, "/lists/search", params, nil, &lists)
 	return lists, err
 }
 
 func (c *Client) GetRatings(mediaType, returnRating string, body RatingsRequest) (*RatingsResponse, error) {
+	return c.GetRatingsContext(context.Background(), mediaType, returnRating, body)
+}
+
+func (c *Client) GetRatingsContext(ctx context.Context, mediaType, returnRating string, body RatingsRequest) (*RatingsResponse, error) {
 	endpoint := fmt.Sprintf("/rating/%s/%s", mediaType, returnRating)
 	var ratings RatingsResponse
-	err := c.doRequest(http.MethodPost, endpoint, nil, body, &ratings)
+	err := c.doRequest(ctx, http.MethodPost, endpoint, nil, body, &ratings)
 	return &ratings, err
 }
 
 func (c *Client) ModifyStaticList(listID int, action string, body ModifyListRequest) (*ModifyListResponse, error) {
+	return c.ModifyStaticListContext(context.Background(), listID, action, body)
+}
+
+func (c *Client) ModifyStaticListContext(ctx context.Context, listID int, action string, body ModifyListRequest) (*ModifyListResponse, error) {
 	endpoint := fmt.Sprintf("/lists/%d/items/%s", listID, action)
 	var response ModifyListResponse
-	err := c.doRequest(http.MethodPost, endpoint, nil, body, &response)
+	err := c.doRequest(ctx, http.MethodPost, endpoint, nil, body, &response)
 	return &response, err
 }
 
 func (c *Client) GetLastActivities() (*LastActivities, error) {
+	return c.GetLastActivitiesContext(context.Background())
+}
+
+func (c *Client) GetLastActivitiesContext(ctx context.Context) (*LastActivities, error) {
 	var activities LastActivities
-	err := c.doRequest(http.MethodGet, "/sync/last_activities", nil, nil, &activities)
+	err := c.doRequest(ctx, http.MethodGet, "/sync/last_activities", nil, nil, &activities)
 	return &activities, err
 }
 
 func (c *Client) GetWatchlistItems(params url.Values) (*WatchlistItems, error) {
+	return c.GetWatchlistItemsContext(context.Background(), params)
+}
+
+func (c *Client) GetWatchlistItemsContext(ctx context.Context, params url.Values) (*WatchlistItems, error) {
 	var items WatchlistItems
-	err := c.doRequest(http.MethodGet, "/watchlist/items", params, nil, &items)
+	err := c.doRequest(ctx, http.MethodGet, "/watchlist/items", params, nil, &items)
 	return &items, err
 }
 
 func (c *Client) ModifyWatchlist(action string, body ModifyListRequest) (*ModifyWatchlistResponse, error) {
+	return c.ModifyWatchlistContext(context.Background(), action, body)
+}
+
+func (c *Client) ModifyWatchlistContext(ctx context.Context, action string, body ModifyListRequest) (*ModifyWatchlistResponse, error) {
 	endpoint := fmt.Sprintf("/watchlist/items/%s", action)
 	var response ModifyWatchlistResponse
-	err := c.doRequest(http.MethodPost, endpoint, nil, body, &response)
+	err := c.doRequest(ctx, http.MethodPost, endpoint, nil, body, &response)
 	return &response, err
 }
 
-func (c *Client) doRequest(method, endpoint string, params url.Values, body interface{}, result interface{}) error {
-	fullURL, err := url.Parse(apiBaseURL + endpoint)
+// doRequest performs a request and makes sure no returned error leaks the
+// API key, which has to travel in the query string as MDBList does not
+// accept it in a header.
+func (c *Client) doRequest(ctx context.Context, method, endpoint string, params url.Values, body interface{}, result interface{}) error {
+	_, err := c.doRequestHeader(ctx, method, endpoint, params, body, result)
+	return err
+}
+
+// doRequestHeader is doRequest for callers that also need the response headers.
+func (c *Client) doRequestHeader(ctx context.Context, method, endpoint string, params url.Values, body interface{}, result interface{}) (http.Header, error) {
+	header, err := c.send(ctx, method, endpoint, params, body, result)
+	return header, c.redactError(err)
+}
+
+func (c *Client) send(ctx context.Context, method, endpoint string, params url.Values, body interface{}, result interface{}) (http.Header, error) {
+	fullURL, err := url.Parse(c.baseURL + endpoint)
 	if err != nil {
-		return fmt.Errorf("failed to parse base URL: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidBaseURL, err)
+	}
+	if (fullURL.Scheme != "http" && fullURL.Scheme != "https") || fullURL.Host == "" {
+		return nil, fmt.Errorf("%w %q: must start with http:// or https://", ErrInvalidBaseURL, c.baseURL)
 	}
 
 	query := fullURL.Query()
@@ -187,46 +300,80 @@ func (c *Client) doRequest(method, endpoint string, params url.Values, body inte
 	query.Set("apikey", c.apiKey)
 	fullURL.RawQuery = query.Encode()
 
-	var reqBody io.Reader
+	var jsonBody []byte
 	if body != nil {
-		jsonBody, err := json.Marshal(body)
+		jsonBody, err = json.Marshal(body)
 		if err != nil {
-			return fmt.Errorf("failed to marshal request body: %w", err)
+			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
-		reqBody = bytes.NewBuffer(jsonBody)
 	}
 
-	req, err := http.NewRequest(method, fullURL.String(), reqBody)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
+	for attempt := 1; ; attempt++ {
+		if c.limiter != nil {
+			if err := c.limiter.wait(ctx); err != nil {
+				return nil, fmt.Errorf("failed to execute request: %w", err)
+			}
+		}
 
-	req.Header.Add("Accept", "application/json")
-	if body != nil {
-		req.Header.Add("Content-Type", "application/json")
-	}
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(jsonBody)
+		}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to execute request: %w", err)
+		req, err := http.NewRequestWithContext(ctx, method, fullURL.String(), reqBody)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		req.Header.Add("Accept", "application/json")
+		if c.userAgent != "" {
+			req.Header.Set("User-Agent", c.userAgent)
+		}
+		if body != nil {
+			req.Header.Add("Content-Type", "application/json")
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if wait, retry := c.retry.nextDelay(ctx, method, attempt, resp, err); retry {
+			if resp != nil {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+			if err := sleepContext(ctx, wait); err != nil {
+				return nil, fmt.Errorf("failed to execute request: %w", err)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute request: %w", err)
+		}
+
+		return resp.Header, c.handleResponse(resp, result)
 	}
+}
+
+func (c *Client) handleResponse(resp *http.Response, result interface{}) error {
 	defer resp.Body.Close()
 
+	if stream, ok := result.(streamResult); ok && resp.StatusCode < 400 {
+		if err := stream.decodeStream(json.NewDecoder(resp.Body)); err != nil {
+			return fmt.Errorf("failed to decode JSON response: %w", err)
+		}
+		return nil
+	}
+
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	if resp.StatusCode >= 400 {
-		return &APIError{
-			StatusCode: resp.StatusCode,
-			Message:    string(respBody),
-		}
+		return newAPIError(resp, c.Redact(string(respBody)))
 	}
 
 	if result != nil {
 		if err := json.Unmarshal(respBody, result); err != nil {
-			return fmt.Errorf("failed to unmarshal JSON response: %w (body: %s)", err, string(respBody))
+			return fmt.Errorf("failed to unmarshal JSON response: %w (body: %s)", err, c.Redact(string(respBody)))
 		}
 	}
 
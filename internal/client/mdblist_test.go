@@ -0,0 +1,511 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+	"github.com/luckylittle/mdblist-cli/internal/mdblisttest"
+)
+
+func newTestClient(t *testing.T, opts ...client.Option) (*mdblisttest.Server, *client.Client) {
+	t.Helper()
+	srv := mdblisttest.NewServer("", mdblisttest.SampleFixtures())
+	t.Cleanup(srv.Close)
+	c, err := srv.Client(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, c
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func imdbRef(id string) map[string]interface{} { return map[string]interface{}{"imdb": id} }
+func tmdbRef(id int) map[string]interface{}    { return map[string]interface{}{"tmdb": id} }
+
+func TestNewRequiresAPIKey(t *testing.T) {
+	if _, err := client.New(""); err == nil {
+		t.Fatal("expected an error for an empty API key")
+	}
+}
+
+func TestInvalidBaseURL(t *testing.T) {
+	for _, base := range []string{"http://[::1", "localhost:8080", "/api"} {
+		c, err := client.New("key", client.WithBaseURL(base))
+		must(t, err)
+		if _, err := c.GetMyLimits(); !errors.Is(err, client.ErrInvalidBaseURL) {
+			t.Errorf("base URL %q: got %v, want ErrInvalidBaseURL", base, err)
+		}
+	}
+}
+
+func TestLimitsAndQuota(t *testing.T) {
+	_, c := newTestClient(t)
+
+	limits, err := c.GetMyLimits()
+	must(t, err)
+	if limits.APIRequests != 1000 || limits.UserID != mdblisttest.SampleUserID {
+		t.Errorf("GetMyLimits = %+v", limits)
+	}
+
+	quota, err := c.CheckQuota(context.Background(), 10)
+	must(t, err)
+	if quota.Limit != 1000 || quota.Remaining() >= 1000 {
+		t.Errorf("CheckQuota = %+v, remaining %d", quota, quota.Remaining())
+	}
+
+	_, err = c.CheckQuota(context.Background(), 5000)
+	var quotaErr *client.QuotaError
+	if !errors.As(err, &quotaErr) || !errors.Is(err, client.ErrQuotaExceeded) {
+		t.Fatalf("CheckQuota(5000) = %v, want a QuotaError", err)
+	}
+	if quotaErr.Needed != 5000 {
+		t.Errorf("QuotaError.Needed = %d", quotaErr.Needed)
+	}
+}
+
+func TestLists(t *testing.T) {
+	_, c := newTestClient(t)
+
+	for name, call := range map[string]func() ([]client.List, error){
+		"GetMyLists":         c.GetMyLists,
+		"GetUserListsByID":   func() ([]client.List, error) { return c.GetUserListsByID(mdblisttest.SampleUserID) },
+		"GetUserListsByName": func() ([]client.List, error) { return c.GetUserListsByName(mdblisttest.SampleUserName) },
+		"GetTopLists":        c.GetTopLists,
+	} {
+		lists, err := call()
+		must(t, err)
+		if len(lists) != 2 {
+			t.Errorf("%s returned %d lists, want 2", name, len(lists))
+		}
+	}
+
+	lists, err := c.GetTopLists()
+	must(t, err)
+	if lists[0].ID != mdblisttest.SampleDynamicListID {
+		t.Errorf("GetTopLists is not sorted by likes: %+v", lists)
+	}
+
+	lists, err = c.SearchLists(url.Values{"query": {"fav"}})
+	must(t, err)
+	if len(lists) != 1 || lists[0].Name != "Faves" {
+		t.Errorf("SearchLists = %+v", lists)
+	}
+
+	lists, err = c.GetListByID(mdblisttest.SampleStaticListID)
+	must(t, err)
+	if len(lists) != 1 || lists[0].Name != "Faves" {
+		t.Errorf("GetListByID = %+v", lists)
+	}
+	lists, err = c.GetListByName(mdblisttest.SampleUserName, "faves")
+	must(t, err)
+	if len(lists) != 1 || lists[0].ID != mdblisttest.SampleStaticListID {
+		t.Errorf("GetListByName = %+v", lists)
+	}
+
+	var top []client.List
+	it := c.TopListsIter(context.Background(), url.Values{"limit": {"1"}})
+	for it.Next() {
+		top = append(top, it.Item())
+	}
+	must(t, it.Err())
+	if len(top) != 2 {
+		t.Errorf("TopListsIter returned %d lists, want 2", len(top))
+	}
+}
+
+func TestUpdateListName(t *testing.T) {
+	_, c := newTestClient(t)
+
+	resp, err := c.UpdateListNameByID(mdblisttest.SampleStaticListID, "Renamed")
+	must(t, err)
+	if !resp.Success || resp.Name != "Renamed" {
+		t.Errorf("UpdateListNameByID = %+v", resp)
+	}
+	resp, err = c.UpdateListNameByName(mdblisttest.SampleUserName, "faves", "Again")
+	must(t, err)
+	if !resp.Success || resp.Name != "Again" {
+		t.Errorf("UpdateListNameByName = %+v", resp)
+	}
+	lists, err := c.GetListByID(mdblisttest.SampleStaticListID)
+	must(t, err)
+	if lists[0].Name != "Again" {
+		t.Errorf("list name = %q after renames", lists[0].Name)
+	}
+}
+
+func TestListItems(t *testing.T) {
+	_, c := newTestClient(t)
+	ctx := context.Background()
+
+	items, err := c.GetListItems(mdblisttest.SampleStaticListID, nil)
+	must(t, err)
+	if len(items.Movies) != 1 || len(items.Shows) != 1 || items.Movies[0].ImdbID != mdblisttest.MatrixIMDb {
+		t.Errorf("GetListItems = %+v", items)
+	}
+
+	items, err = c.GetListItems(mdblisttest.SampleStaticListID, url.Values{"unified": {"true"}})
+	must(t, err)
+	if len(items.Movies) != 1 || len(items.Shows) != 1 {
+		t.Errorf("GetListItems(unified) = %+v", items)
+	}
+
+	items, err = c.GetListItemsByName(mdblisttest.SampleUserName, "faves", nil)
+	must(t, err)
+	if len(items.Movies)+len(items.Shows) != 2 {
+		t.Errorf("GetListItemsByName = %+v", items)
+	}
+
+	page, pagination, err := c.GetListItemsPageContext(ctx, mdblisttest.SampleStaticListID, url.Values{"limit": {"1"}})
+	must(t, err)
+	if len(page.Movies) != 1 || !pagination.HasMore || pagination.Total != 2 {
+		t.Errorf("GetListItemsPageContext = %+v, %+v", page, pagination)
+	}
+	page, pagination, err = c.GetListItemsByNamePageContext(ctx, mdblisttest.SampleUserName, "faves", url.Values{"limit": {"1"}, "offset": {"1"}})
+	must(t, err)
+	if len(page.Shows) != 1 || pagination.HasMore {
+		t.Errorf("GetListItemsByNamePageContext = %+v, %+v", page, pagination)
+	}
+
+	iters := map[string]*client.Iterator[client.ListItem]{
+		"ListItemsIter":       c.ListItemsIter(ctx, mdblisttest.SampleStaticListID, url.Values{"limit": {"1"}}),
+		"ListItemsByNameIter": c.ListItemsByNameIter(ctx, mdblisttest.SampleUserName, "faves", url.Values{"limit": {"1"}}),
+	}
+	for name, it := range iters {
+		var titles []string
+		for it.Next() {
+			titles = append(titles, it.Item().Title)
+		}
+		must(t, it.Err())
+		if len(titles) != 2 || titles[0] != "The Matrix" || titles[1] != "Dark" {
+			t.Errorf("%s = %v", name, titles)
+		}
+	}
+
+	streams := map[string]func(func(client.ListItem) error) error{
+		"StreamListItemsContext": func(fn func(client.ListItem) error) error {
+			return c.StreamListItemsContext(ctx, mdblisttest.SampleStaticListID, nil, fn)
+		},
+		"StreamListItemsByNameContext": func(fn func(client.ListItem) error) error {
+			return c.StreamListItemsByNameContext(ctx, mdblisttest.SampleUserName, "faves", url.Values{"unified": {"true"}}, fn)
+		},
+	}
+	for name, stream := range streams {
+		n := 0
+		must(t, stream(func(client.ListItem) error { n++; return nil }))
+		if n != 2 {
+			t.Errorf("%s streamed %d items, want 2", name, n)
+		}
+	}
+
+	stop := errors.New("stop")
+	err = c.StreamListItemsContext(ctx, mdblisttest.SampleStaticListID, nil, func(client.ListItem) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Errorf("StreamListItemsContext did not return the callback error: %v", err)
+	}
+}
+
+func TestModifyListItems(t *testing.T) {
+	srv, c := newTestClient(t)
+
+	resp, err := c.ModifyListItems(mdblisttest.SampleStaticListID, "add", client.ModifyListRequest{
+		Movies: []map[string]interface{}{imdbRef(mdblisttest.MatrixReloadedIMDb), tmdbRef(mdblisttest.MatrixTMDb), imdbRef("tt0000000")},
+	})
+	must(t, err)
+	if resp.Added["movies"] != 1 || resp.Existing["movies"] != 1 || resp.NotFound["movies"] != 1 {
+		t.Errorf("ModifyListItems(add) = %+v", resp)
+	}
+
+	static, err := c.ModifyStaticList(mdblisttest.SampleStaticListID, "remove", client.ModifyListRequest{
+		Shows: []map[string]interface{}{imdbRef(mdblisttest.DarkIMDb)},
+	})
+	must(t, err)
+	if static.NotFound.Shows != 0 {
+		t.Errorf("ModifyStaticList(remove) = %+v", static)
+	}
+	if items := srv.ListItems(mdblisttest.SampleStaticListID); len(items.Movies) != 2 || len(items.Shows) != 0 {
+		t.Errorf("list holds %d movies and %d shows after the changes", len(items.Movies), len(items.Shows))
+	}
+
+	changes, err := c.GetListChanges(mdblisttest.SampleStaticListID)
+	must(t, err)
+	if len(changes.Movie.TraktIDs.Added) != 1 || changes.Updated.IsZero() {
+		t.Errorf("GetListChanges = %+v", changes)
+	}
+
+	_, err = c.ModifyListItems(mdblisttest.SampleDynamicListID, "add", client.ModifyListRequest{Movies: []map[string]interface{}{tmdbRef(mdblisttest.MatrixTMDb)}})
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("ModifyListItems on a dynamic list = %v, want a 400 APIError", err)
+	}
+}
+
+func TestModifyListItemsBatched(t *testing.T) {
+	srv, c := newTestClient(t)
+	req := client.ModifyListRequest{
+		Movies: []map[string]interface{}{imdbRef(mdblisttest.MatrixIMDb), imdbRef(mdblisttest.MatrixReloadedIMDb)},
+		Shows:  []map[string]interface{}{imdbRef(mdblisttest.DarkIMDb)},
+	}
+
+	batches := 0
+	resp, err := c.ModifyListItemsBatched(mdblisttest.SampleStaticListID, "remove", req, client.BatchOptions{
+		Size:        1,
+		Parallelism: 2,
+		OnBatch:     func(index, total int, _ *client.ModifyListItemsResponse) { batches++ },
+	})
+	must(t, err)
+	if batches != 3 || resp.NotFound["movies"] != 1 {
+		t.Errorf("ModifyListItemsBatched ran %d batches, response %+v", batches, resp)
+	}
+	if items := srv.ListItems(mdblisttest.SampleStaticListID); len(items.Movies)+len(items.Shows) != 0 {
+		t.Errorf("list still holds %+v", items)
+	}
+
+	srv.FailNext(1, http.StatusInternalServerError, "boom")
+	_, err = c.ModifyListItemsBatchedContext(context.Background(), mdblisttest.SampleStaticListID, "add", req, client.BatchOptions{Size: 2})
+	var batchErr *client.BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected a BatchError, got %v", err)
+	}
+	if batchErr.Index != 0 || batchErr.Total != 2 || batchErr.Offset != 0 || batchErr.Size != 2 {
+		t.Errorf("BatchError = %+v", batchErr)
+	}
+	if items := srv.ListItems(mdblisttest.SampleStaticListID); len(items.Movies)+len(items.Shows) != 0 {
+		t.Errorf("batches after the failed one were sent: %+v", items)
+	}
+}
+
+func TestMedia(t *testing.T) {
+	_, c := newTestClient(t)
+	ctx := context.Background()
+
+	info, err := c.GetMediaInfo("imdb", "movie", mdblisttest.MatrixIMDb, nil)
+	must(t, err)
+	if info.Title != "The Matrix" || info.IDs.Tmdb != mdblisttest.MatrixTMDb {
+		t.Errorf("GetMediaInfo = %+v", info)
+	}
+
+	infos, err := c.GetMediaInfoBatch("imdb", "movie", client.MediaInfoBatchRequest{IDs: []string{mdblisttest.MatrixIMDb, mdblisttest.MatrixReloadedIMDb, "tt0000000"}})
+	must(t, err)
+	if len(infos) != 2 {
+		t.Errorf("GetMediaInfoBatch returned %d titles, want 2", len(infos))
+	}
+
+	result, err := c.SearchMedia("movie", url.Values{"query": {"matrix"}})
+	must(t, err)
+	if result.Total != 2 || len(result.Search) != 2 {
+		t.Errorf("SearchMedia = %+v", result)
+	}
+
+	var hits []string
+	it := c.SearchMediaIter(ctx, "any", url.Values{"query": {"a"}, "limit": {"1"}})
+	for it.Next() {
+		hits = append(hits, it.Item().Title)
+	}
+	must(t, it.Err())
+	if len(hits) != 3 {
+		t.Errorf("SearchMediaIter = %v, want 3 hits", hits)
+	}
+
+	n := 0
+	must(t, c.StreamSearchMediaContext(ctx, "show", url.Values{"query": {"dark"}}, func(client.SearchItem) error { n++; return nil }))
+	if n != 1 {
+		t.Errorf("StreamSearchMediaContext streamed %d hits, want 1", n)
+	}
+
+	ratings, err := c.GetRatings("movie", "imdb", client.RatingsRequest{IDs: []int{mdblisttest.MatrixTMDb, mdblisttest.MatrixReloadedTMDb}, Provider: "tmdb"})
+	must(t, err)
+	if len(ratings.Ratings) != 2 || ratings.Ratings[0].Rating != 8.7 {
+		t.Errorf("GetRatings = %+v", ratings)
+	}
+}
+
+func TestWatchlist(t *testing.T) {
+	_, c := newTestClient(t)
+	ctx := context.Background()
+
+	items, err := c.GetWatchlistItems(nil)
+	must(t, err)
+	if len(items.Movies) != 1 || items.Movies[0].ImdbID != mdblisttest.MatrixReloadedIMDb {
+		t.Errorf("GetWatchlistItems = %+v", items)
+	}
+
+	resp, err := c.ModifyWatchlist("add", client.ModifyListRequest{
+		Movies: []map[string]interface{}{tmdbRef(mdblisttest.MatrixReloadedTMDb)},
+		Shows:  []map[string]interface{}{imdbRef(mdblisttest.DarkIMDb)},
+	})
+	must(t, err)
+	if resp.Added.Shows != 1 || resp.Existing.Movies != 1 {
+		t.Errorf("ModifyWatchlist(add) = %+v", resp)
+	}
+
+	var titles []string
+	it := c.WatchlistItemsIter(ctx, url.Values{"limit": {"1"}})
+	for it.Next() {
+		titles = append(titles, it.Item().Title)
+	}
+	must(t, it.Err())
+	if len(titles) != 2 {
+		t.Errorf("WatchlistItemsIter = %v", titles)
+	}
+
+	n := 0
+	must(t, c.StreamWatchlistItemsContext(ctx, nil, func(client.WatchlistItem) error { n++; return nil }))
+	if n != 2 {
+		t.Errorf("StreamWatchlistItemsContext streamed %d items, want 2", n)
+	}
+
+	activities, err := c.GetLastActivities()
+	must(t, err)
+	if activities.WatchlistedAt.Year() < 2025 {
+		t.Errorf("GetLastActivities was not updated by the change: %+v", activities)
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		fail     int
+		message  string
+		status   int
+		sentinel error
+	}{
+		{name: "unauthorized", key: "wrong-key", status: http.StatusUnauthorized, sentinel: client.ErrUnauthorized},
+		{name: "not found", status: http.StatusNotFound, sentinel: client.ErrNotFound},
+		{name: "rate limited", fail: http.StatusTooManyRequests, message: "Too many requests", status: http.StatusTooManyRequests, sentinel: client.ErrRateLimited},
+		{name: "quota exceeded", fail: http.StatusTooManyRequests, message: "API Limit Reached!", status: http.StatusTooManyRequests, sentinel: client.ErrQuotaExceeded},
+		{name: "server error", fail: http.StatusInternalServerError, message: "Internal error", status: http.StatusInternalServerError},
+		{name: "bad gateway", fail: http.StatusBadGateway, message: "Bad gateway", status: http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := mdblisttest.NewServer("", mdblisttest.SampleFixtures())
+			defer srv.Close()
+			key := srv.APIKey
+			if tt.key != "" {
+				key = tt.key
+			}
+			c, err := client.New(key, client.WithBaseURL(srv.URL))
+			must(t, err)
+			if tt.fail != 0 {
+				srv.FailNextRetryAfter(1, tt.fail, tt.message, "7")
+			}
+
+			listID := mdblisttest.SampleStaticListID
+			if tt.status == http.StatusNotFound {
+				listID = 999
+			}
+			_, err = c.GetListItems(listID, nil)
+			var apiErr *client.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("expected an APIError, got %v", err)
+			}
+			if apiErr.StatusCode != tt.status {
+				t.Errorf("StatusCode = %d, want %d", apiErr.StatusCode, tt.status)
+			}
+			if apiErr.Message == "" || apiErr.Message == apiErr.Body {
+				t.Errorf("Message %q was not parsed from the body %q", apiErr.Message, apiErr.Body)
+			}
+			if tt.sentinel != nil && !errors.Is(err, tt.sentinel) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.sentinel)
+			}
+			if tt.fail != 0 && apiErr.RetryAfter.Seconds() != 7 {
+				t.Errorf("RetryAfter = %v, want 7s", apiErr.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestRetryRecoversFromTransientErrors(t *testing.T) {
+	srv, c := newTestClient(t, client.WithRetryPolicy(client.RetryPolicy{MaxAttempts: 3, BaseDelay: 1}))
+
+	srv.FailNext(2, http.StatusServiceUnavailable, "Unavailable")
+	_, err := c.GetMyLimits()
+	must(t, err)
+	if srv.Requests() != 3 {
+		t.Errorf("served %d requests, want 3", srv.Requests())
+	}
+
+	// POST is not retried unless RetryPOST is set
+	srv.FailNext(1, http.StatusServiceUnavailable, "Unavailable")
+	_, err = c.ModifyWatchlist("add", client.ModifyListRequest{Shows: []map[string]interface{}{imdbRef(mdblisttest.DarkIMDb)}})
+	if err == nil {
+		t.Error("expected the POST to fail without a retry")
+	}
+}
+
+// Every Context method must stop before sending anything once the context
+// is cancelled.
+func TestContextMethodsHonourCancellation(t *testing.T) {
+	srv, c := newTestClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	id, user, list := mdblisttest.SampleStaticListID, mdblisttest.SampleUserName, "faves"
+	req := client.ModifyListRequest{Movies: []map[string]interface{}{imdbRef(mdblisttest.MatrixIMDb)}}
+	calls := map[string]func() error{
+		"GetMyLimitsContext":        func() error { _, err := c.GetMyLimitsContext(ctx); return err },
+		"GetMyListsContext":         func() error { _, err := c.GetMyListsContext(ctx); return err },
+		"GetUserListsByIDContext":   func() error { _, err := c.GetUserListsByIDContext(ctx, 7); return err },
+		"GetUserListsByNameContext": func() error { _, err := c.GetUserListsByNameContext(ctx, user); return err },
+		"GetListByIDContext":        func() error { _, err := c.GetListByIDContext(ctx, id); return err },
+		"GetListByNameContext":      func() error { _, err := c.GetListByNameContext(ctx, user, list); return err },
+		"UpdateListNameByIDContext": func() error { _, err := c.UpdateListNameByIDContext(ctx, id, "x"); return err },
+		"UpdateListNameByNameContext": func() error {
+			_, err := c.UpdateListNameByNameContext(ctx, user, list, "x")
+			return err
+		},
+		"GetListItemsContext":       func() error { _, err := c.GetListItemsContext(ctx, id, nil); return err },
+		"GetListItemsByNameContext": func() error { _, err := c.GetListItemsByNameContext(ctx, user, list, nil); return err },
+		"GetListChangesContext":     func() error { _, err := c.GetListChangesContext(ctx, id); return err },
+		"GetMediaInfoContext": func() error {
+			_, err := c.GetMediaInfoContext(ctx, "imdb", "movie", mdblisttest.MatrixIMDb, nil)
+			return err
+		},
+		"GetMediaInfoBatchContext": func() error {
+			_, err := c.GetMediaInfoBatchContext(ctx, "imdb", "movie", client.MediaInfoBatchRequest{IDs: []string{mdblisttest.MatrixIMDb}})
+			return err
+		},
+		"SearchMediaContext": func() error { _, err := c.SearchMediaContext(ctx, "any", nil); return err },
+		"GetTopListsContext": func() error { _, err := c.GetTopListsContext(ctx); return err },
+		"SearchListsContext": func() error { _, err := c.SearchListsContext(ctx, nil); return err },
+		"GetRatingsContext":  func() error { _, err := c.GetRatingsContext(ctx, "movie", "imdb", client.RatingsRequest{}); return err },
+		"ModifyStaticListContext": func() error {
+			_, err := c.ModifyStaticListContext(ctx, id, "add", req)
+			return err
+		},
+		"ModifyListItemsContext":      func() error { _, err := c.ModifyListItemsContext(ctx, id, "add", req); return err },
+		"GetLastActivitiesContext":    func() error { _, err := c.GetLastActivitiesContext(ctx); return err },
+		"GetWatchlistItemsContext":    func() error { _, err := c.GetWatchlistItemsContext(ctx, nil); return err },
+		"ModifyWatchlistContext":      func() error { _, err := c.ModifyWatchlistContext(ctx, "add", req); return err },
+		"CheckQuota":                  func() error { _, err := c.CheckQuota(ctx, 1); return err },
+		"StreamListItemsContext":      func() error { return c.StreamListItemsContext(ctx, id, nil, nil) },
+		"StreamWatchlistItemsContext": func() error { return c.StreamWatchlistItemsContext(ctx, nil, nil) },
+		"StreamSearchMediaContext":    func() error { return c.StreamSearchMediaContext(ctx, "any", nil, nil) },
+		"ListItemsIter": func() error {
+			it := c.ListItemsIter(ctx, id, nil)
+			for it.Next() {
+			}
+			return it.Err()
+		},
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s = %v, want context.Canceled", name, err)
+		}
+	}
+	if srv.Requests() != 0 {
+		t.Errorf("%d requests reached the server", srv.Requests())
+	}
+}
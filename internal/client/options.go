@@ -0,0 +1,53 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package client
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL points the client at a different API root, such as a local
+// stub or a caching proxy. An empty URL keeps the default.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		if baseURL != "" {
+			c.baseURL = strings.TrimRight(baseURL, "/")
+		}
+	}
+}
+
+// WithHTTPClient replaces the underlying HTTP client.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		if hc != nil {
+			c.httpClient = hc
+		}
+	}
+}
+
+// WithTransport sets the transport of the underlying HTTP client, e.g. to
+// route requests through a corporate egress proxy.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		hc := *c.httpClient
+		hc.Transport = rt
+		c.httpClient = &hc
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithRetryPolicy sets the retry policy used for every request.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
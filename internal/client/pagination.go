@@ -0,0 +1,206 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Pagination describes where a page of results sits in the full result set.
+type Pagination struct {
+	Offset  int
+	Limit   int
+	HasMore bool
+	// Total is the total number of items, or -1 when the API did not say.
+	Total int
+}
+
+// parsePagination reads the X-Has-More and X-Total-Items headers. Without
+// X-Has-More, a page holding fewer than limit items is taken as the last one.
+func parsePagination(h http.Header, params url.Values, count int) Pagination {
+	p := Pagination{Total: -1}
+	p.Offset, _ = strconv.Atoi(params.Get("offset"))
+	p.Limit, _ = strconv.Atoi(params.Get("limit"))
+
+	if total, err := strconv.Atoi(h.Get("X-Total-Items")); err == nil {
+		p.Total = total
+	}
+	if hasMore, err := strconv.ParseBool(h.Get("X-Has-More")); err == nil {
+		p.HasMore = hasMore
+	} else if p.Total >= 0 {
+		p.HasMore = p.Offset+count < p.Total
+	} else {
+		p.HasMore = p.Limit > 0 && count >= p.Limit
+	}
+	return p
+}
+
+func (items *ListItems) count() int {
+	return len(items.Movies) + len(items.Shows)
+}
+
+// GetListItemsPageContext fetches a single page of a list selected by the
+// limit and offset params, together with its pagination.
+func (c *Client) GetListItemsPageContext(ctx context.Context, listID int, params url.Values) (*ListItems, Pagination, error) {
+	return c.listItemsPage(ctx, fmt.Sprintf("/lists/%d/items", listID), params)
+}
+
+// GetListItemsByNamePageContext is GetListItemsPageContext for a list
+// addressed by its owner and name.
+func (c *Client) GetListItemsByNamePageContext(ctx context.Context, username, listname string, params url.Values) (*ListItems, Pagination, error) {
+	return c.listItemsPage(ctx, fmt.Sprintf("/lists/%s/%s/items", username, listname), params)
+}
+
+func (c *Client) listItemsPage(ctx context.Context, endpoint string, params url.Values) (*ListItems, Pagination, error) {
+	var items ListItems
+	header, err := c.doRequestHeader(ctx, http.MethodGet, endpoint, params, nil, &items)
+	if err != nil {
+		return &items, Pagination{}, err
+	}
+	return &items, parsePagination(header, params, items.count()), nil
+}
+
+// DefaultPageSize is the number of items an Iterator requests per page when
+// the params do not set a limit.
+const DefaultPageSize = 100
+
+// Iterator walks a paginated endpoint one page at a time, so only the
+// current page is held in memory. Callers may stop at any point:
+//
+//	it := c.ListItemsIter(ctx, listID, nil)
+//	for it.Next() {
+//		item := it.Item()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator[T any] struct {
+	ctx    context.Context
+	fetch  func(ctx context.Context, params url.Values) ([]T, Pagination, error)
+	params url.Values
+	offset int
+
+	page []T
+	pos  int
+	cur  T
+	last bool
+	err  error
+}
+
+func newIterator[T any](ctx context.Context, params url.Values, fetch func(context.Context, url.Values) ([]T, Pagination, error)) *Iterator[T] {
+	p := url.Values{}
+	for k, v := range params {
+		p[k] = append([]string(nil), v...)
+	}
+	if p.Get("limit") == "" {
+		p.Set("limit", strconv.Itoa(DefaultPageSize))
+	}
+	offset, _ := strconv.Atoi(p.Get("offset"))
+	return &Iterator[T]{ctx: ctx, fetch: fetch, params: p, offset: offset}
+}
+
+// Next advances to the next item, fetching the next page when needed. It
+// returns false when the items are exhausted or an error occurred.
+func (it *Iterator[T]) Next() bool {
+	for it.pos >= len(it.page) {
+		if it.last || it.err != nil {
+			return false
+		}
+		it.params.Set("offset", strconv.Itoa(it.offset))
+		page, pagination, err := it.fetch(it.ctx, it.params)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page, it.pos = page, 0
+		it.offset += len(page)
+		it.last = !pagination.HasMore || len(page) == 0
+	}
+	it.cur = it.page[it.pos]
+	it.pos++
+	return true
+}
+
+// Item returns the current item.
+func (it *Iterator[T]) Item() T {
+	return it.cur
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Offset returns the offset of the next page to be fetched.
+func (it *Iterator[T]) Offset() int {
+	return it.offset
+}
+
+func flattenListItems(items *ListItems) []ListItem {
+	return append(append(make([]ListItem, 0, items.count()), items.Movies...), items.Shows...)
+}
+
+// ListItemsIter iterates over the items of a list by ID, movies before shows
+// within each page.
+func (c *Client) ListItemsIter(ctx context.Context, listID int, params url.Values) *Iterator[ListItem] {
+	return newIterator(ctx, params, func(ctx context.Context, p url.Values) ([]ListItem, Pagination, error) {
+		items, pagination, err := c.GetListItemsPageContext(ctx, listID, p)
+		return flattenListItems(items), pagination, err
+	})
+}
+
+// ListItemsByNameIter iterates over the items of a list addressed by its
+// owner and name, movies before shows within each page.
+func (c *Client) ListItemsByNameIter(ctx context.Context, username, listname string, params url.Values) *Iterator[ListItem] {
+	return newIterator(ctx, params, func(ctx context.Context, p url.Values) ([]ListItem, Pagination, error) {
+		items, pagination, err := c.GetListItemsByNamePageContext(ctx, username, listname, p)
+		return flattenListItems(items), pagination, err
+	})
+}
+
+// WatchlistItemsIter iterates over the watchlist, movies before shows within
+// each page.
+func (c *Client) WatchlistItemsIter(ctx context.Context, params url.Values) *Iterator[WatchlistItem] {
+	return newIterator(ctx, params, func(ctx context.Context, p url.Values) ([]WatchlistItem, Pagination, error) {
+		var items WatchlistItems
+		header, err := c.doRequestHeader(ctx, http.MethodGet, "/watchlist/items", p, nil, &items)
+		if err != nil {
+			return nil, Pagination{}, err
+		}
+		all := append(append([]WatchlistItem{}, items.Movies...), items.Shows...)
+		return all, parsePagination(header, p, len(all)), nil
+	})
+}
+
+// SearchMediaIter iterates over every hit of a media search.
+func (c *Client) SearchMediaIter(ctx context.Context, mediaType string, params url.Values) *Iterator[SearchItem] {
+	endpoint := fmt.Sprintf("/search/%s", mediaType)
+	return newIterator(ctx, params, func(ctx context.Context, p url.Values) ([]SearchItem, Pagination, error) {
+		var result SearchResult
+		header, err := c.doRequestHeader(ctx, http.MethodGet, endpoint, p, nil, &result)
+		if err != nil {
+			return nil, Pagination{}, err
+		}
+		if header.Get("X-Total-Items") == "" && result.Total > 0 {
+			header = header.Clone()
+			header.Set("X-Total-Items", strconv.Itoa(result.Total))
+		}
+		return result.Search, parsePagination(header, p, len(result.Search)), nil
+	})
+}
+
+// TopListsIter iterates over the top lists.
+func (c *Client) TopListsIter(ctx context.Context, params url.Values) *Iterator[List] {
+	return newIterator(ctx, params, func(ctx context.Context, p url.Values) ([]List, Pagination, error) {
+		var lists []List
+		header, err := c.doRequestHeader(ctx, http.MethodGet, "/lists/top", p, nil, &lists)
+		if err != nil {
+			return nil, Pagination{}, err
+		}
+		return lists, parsePagination(header, p, len(lists)), nil
+	})
+}
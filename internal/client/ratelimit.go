@@ -0,0 +1,106 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a simple token bucket shared by all requests of a Client.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens added per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   perSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until a token is available or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// WithRateLimit limits the client to perSecond requests per second, allowing
+// bursts of up to burst requests. A non-positive rate disables the limiter.
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(c *Client) {
+		if perSecond <= 0 {
+			c.limiter = nil
+			return
+		}
+		c.limiter = newRateLimiter(perSecond, burst)
+	}
+}
+
+// Quota describes the daily request budget reported by GetMyLimits.
+type Quota struct {
+	Limit int
+	Used  int
+}
+
+// Remaining returns the number of requests left for today.
+func (q Quota) Remaining() int {
+	if q.Used >= q.Limit {
+		return 0
+	}
+	return q.Limit - q.Used
+}
+
+// QuotaError is returned by CheckQuota when an operation needs more requests
+// than the daily quota has left.
+type QuotaError struct {
+	Needed    int
+	Remaining int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("operation needs %d API requests but only %d remain in today's quota", e.Needed, e.Remaining)
+}
+
+// CheckQuota fetches the current limits and returns a *QuotaError if needed
+// requests would exceed the remaining daily budget. The quota is returned
+// even when the check fails.
+func (c *Client) CheckQuota(ctx context.Context, needed int) (*Quota, error) {
+	limits, err := c.GetMyLimitsContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch API limits: %w", err)
+	}
+	quota := &Quota{Limit: limits.APIRequests, Used: limits.APIRequestsCount}
+	if needed > quota.Remaining() {
+		return quota, &QuotaError{Needed: needed, Remaining: quota.Remaining()}
+	}
+	return quota, nil
+}
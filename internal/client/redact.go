@@ -0,0 +1,52 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package client
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces the API key wherever the client reports a URL or body.
+const Redacted = "REDACTED"
+
+// Redact removes every occurrence of the client's API key from s, including
+// its URL-encoded forms.
+func (c *Client) Redact(s string) string {
+	return redactKey(s, c.apiKey)
+}
+
+func redactKey(s, key string) string {
+	if key == "" {
+		return s
+	}
+	for _, k := range []string{key, url.QueryEscape(key), url.PathEscape(key)} {
+		s = strings.ReplaceAll(s, k, Redacted)
+	}
+	return s
+}
+
+// redactedError hides the API key in the message of the wrapped error while
+// keeping it available to errors.Is and errors.As.
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+func (c *Client) redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = c.Redact(urlErr.URL)
+	}
+	msg := c.Redact(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{err: err, msg: msg}
+}
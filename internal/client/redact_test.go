@@ -0,0 +1,163 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+)
+
+// secretKey contains characters that change when URL-encoded, so both the
+// raw and the encoded forms have to be redacted.
+const secretKey = "s3cr+t/k3y=="
+
+func assertNoKey(t *testing.T, what, s string) {
+	t.Helper()
+	for _, form := range []string{secretKey, url.QueryEscape(secretKey), url.PathEscape(secretKey)} {
+		if strings.Contains(s, form) {
+			t.Errorf("%s leaks the API key as %q: %s", what, form, s)
+		}
+	}
+}
+
+// echoServer answers every request with status and a body quoting the API
+// key exactly as it was received.
+func echoServer(t *testing.T, status int, format string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Echo", r.URL.Query().Get("apikey"))
+		w.WriteHeader(status)
+		fmt.Fprintf(w, format, r.URL.Query().Get("apikey"), r.URL.RawQuery)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRedactTransportError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	c, err := client.New(secretKey, client.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.GetMyLimits()
+	if err == nil {
+		t.Fatal("expected an error from a closed server")
+	}
+	assertNoKey(t, "error", err.Error())
+
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		t.Fatalf("expected a *url.Error in the chain, got %T", err)
+	}
+	assertNoKey(t, "url.Error.URL", urlErr.URL)
+	if !strings.Contains(urlErr.URL, client.Redacted) {
+		t.Errorf("url.Error.URL = %q, want the key replaced by %s", urlErr.URL, client.Redacted)
+	}
+}
+
+func TestRedactAPIErrorBody(t *testing.T) {
+	srv := echoServer(t, http.StatusBadRequest, `{"error": "bad key %s in %s"}`)
+	c, _ := client.New(secretKey, client.WithBaseURL(srv.URL))
+
+	_, err := c.GetMyLimits()
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *client.APIError, got %T: %v", err, err)
+	}
+	assertNoKey(t, "error", err.Error())
+	assertNoKey(t, "APIError.Message", apiErr.Message)
+	assertNoKey(t, "APIError.Body", apiErr.Body)
+}
+
+func TestRedactUnmarshalError(t *testing.T) {
+	srv := echoServer(t, http.StatusOK, `not json: %s %s`)
+	c, _ := client.New(secretKey, client.WithBaseURL(srv.URL))
+
+	_, err := c.GetMyLimits()
+	if err == nil || !strings.Contains(err.Error(), "failed to unmarshal JSON response") {
+		t.Fatalf("expected an unmarshal error, got %v", err)
+	}
+	assertNoKey(t, "error", err.Error())
+}
+
+func TestRedactRetryCancellation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, `{"error": "down for %s"}`, r.URL.Query().Get("apikey"))
+	}))
+	defer srv.Close()
+	c, _ := client.New(secretKey,
+		client.WithBaseURL(srv.URL),
+		client.WithRetryPolicy(client.RetryPolicy{MaxAttempts: 5, MaxWait: time.Minute}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.GetMyLimitsContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the retry wait to be cancelled, got %v", err)
+	}
+	assertNoKey(t, "error", err.Error())
+}
+
+func TestRedactLimiterCancellation(t *testing.T) {
+	srv := echoServer(t, http.StatusOK, `{"user_id": 1, "username": "%s", "x": %q}`)
+	c, _ := client.New(secretKey,
+		client.WithBaseURL(srv.URL),
+		client.WithRateLimit(0.001, 1))
+
+	if _, err := c.GetMyLimits(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.GetMyLimitsContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the limiter wait to be cancelled, got %v", err)
+	}
+	assertNoKey(t, "error", err.Error())
+}
+
+func TestRedactCassette(t *testing.T) {
+	srv := echoServer(t, http.StatusOK, `{"name": "%s", "query": %q}`)
+	dir := t.TempDir()
+	c, _ := client.New(secretKey, client.WithBaseURL(srv.URL), client.WithRecorder(dir))
+
+	// the key also travels in a request body and in a response header
+	if _, err := c.UpdateListNameByID(1, "renamed to "+secretKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetListItems(1, url.Values{"limit": {"1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("recorded %d interactions, want 2", len(files))
+	}
+	for _, name := range files {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertNoKey(t, filepath.Base(name), string(b))
+		if !strings.Contains(string(b), client.Redacted) {
+			t.Errorf("%s: expected the echoed key to be replaced by %s", filepath.Base(name), client.Redacted)
+		}
+	}
+}
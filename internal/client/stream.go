@@ -0,0 +1,150 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// streamResult is implemented by results that decode the response body as
+// it arrives instead of buffering it.
+type streamResult interface {
+	decodeStream(dec *json.Decoder) error
+}
+
+// streamFunc adapts a function to streamResult.
+type streamFunc func(dec *json.Decoder) error
+
+func (f streamFunc) decodeStream(dec *json.Decoder) error {
+	return f(dec)
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != want {
+		return fmt.Errorf("expected %q, got %v", want, tok)
+	}
+	return nil
+}
+
+// decodeElements calls fn for every element of an array whose '[' was
+// already read, and consumes the closing ']'.
+func decodeElements[T any](dec *json.Decoder, fn func(T) error) error {
+	for dec.More() {
+		var v T
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+// arrayOf returns a handler calling fn for every element of a JSON array.
+// A null value is treated as an empty array.
+func arrayOf[T any](fn func(T) error) func(*json.Decoder) error {
+	return func(dec *json.Decoder) error {
+		tok, err := dec.Token()
+		if err != nil || tok == nil {
+			return err
+		}
+		if d, ok := tok.(json.Delim); !ok || d != '[' {
+			return fmt.Errorf("expected an array, got %v", tok)
+		}
+		return decodeElements(dec, fn)
+	}
+}
+
+// decodeFields walks an object whose '{' was already read, passing the
+// decoder to the handler of each known key and skipping other values.
+func decodeFields(dec *json.Decoder, handlers map[string]func(*json.Decoder) error) error {
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		handler, ok := handlers[key]
+		if !ok {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := handler(dec); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+func decodeObject(dec *json.Decoder, handlers map[string]func(*json.Decoder) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	return decodeFields(dec, handlers)
+}
+
+// listItemsStream handles both the movies/shows object and the single array
+// returned for unified=true.
+func listItemsStream(fn func(ListItem) error) streamFunc {
+	return func(dec *json.Decoder) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('['):
+			return decodeElements(dec, fn)
+		case json.Delim('{'):
+			return decodeFields(dec, map[string]func(*json.Decoder) error{
+				"movies": arrayOf(fn),
+				"shows":  arrayOf(fn),
+			})
+		}
+		return fmt.Errorf("unexpected %v in list items", tok)
+	}
+}
+
+// StreamListItemsContext calls fn for every item of a list as it is decoded
+// from the response, without holding the whole list in memory.
+func (c *Client) StreamListItemsContext(ctx context.Context, listID int, params url.Values, fn func(ListItem) error) error {
+	endpoint := fmt.Sprintf("/lists/%d/items", listID)
+	return c.doRequest(ctx, http.MethodGet, endpoint, params, nil, listItemsStream(fn))
+}
+
+// StreamListItemsByNameContext is StreamListItemsContext for a list
+// addressed by its owner and name.
+func (c *Client) StreamListItemsByNameContext(ctx context.Context, username, listname string, params url.Values, fn func(ListItem) error) error {
+	endpoint := fmt.Sprintf("/lists/%s/%s/items", username, listname)
+	return c.doRequest(ctx, http.MethodGet, endpoint, params, nil, listItemsStream(fn))
+}
+
+// StreamWatchlistItemsContext calls fn for every watchlist entry as it is decoded.
+func (c *Client) StreamWatchlistItemsContext(ctx context.Context, params url.Values, fn func(WatchlistItem) error) error {
+	return c.doRequest(ctx, http.MethodGet, "/watchlist/items", params, nil, streamFunc(func(dec *json.Decoder) error {
+		return decodeObject(dec, map[string]func(*json.Decoder) error{
+			"movies": arrayOf(fn),
+			"shows":  arrayOf(fn),
+		})
+	}))
+}
+
+// StreamSearchMediaContext calls fn for every search hit as it is decoded.
+func (c *Client) StreamSearchMediaContext(ctx context.Context, mediaType string, params url.Values, fn func(SearchItem) error) error {
+	endpoint := fmt.Sprintf("/search/%s", mediaType)
+	return c.doRequest(ctx, http.MethodGet, endpoint, params, nil, streamFunc(func(dec *json.Decoder) error {
+		return decodeObject(dec, map[string]func(*json.Decoder) error{
+			"search": arrayOf(fn),
+		})
+	}))
+}
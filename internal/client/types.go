@@ -2,6 +2,9 @@
 package client
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
@@ -9,241 +12,273 @@ import (
 
 // MyLimits represents the user's API limits.
 type MyLimits struct {
-	APIRequests      int    `json:"api_requests"`
-	APIRequestsCount int    `json:"api_requests_count"`
-	UserID           int    `json:"user_id"`
-	PatronStatus     string `json:"patron_status"`
-	PatreonPledge    int    `json:"patreon_pledge"`
+	APIRequests      int    `json:"api_requests" yaml:"api_requests"`
+	APIRequestsCount int    `json:"api_requests_count" yaml:"api_requests_count"`
+	UserID           int    `json:"user_id" yaml:"user_id"`
+	PatronStatus     string `json:"patron_status" yaml:"patron_status"`
+	PatreonPledge    int    `json:"patreon_pledge" yaml:"patreon_pledge"`
 }
 
 // List represents a single list.
 type List struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Slug        string `json:"slug"`
-	Description string `json:"description"`
-	MediaType   string `json:"mediatype"`
-	Items       int    `json:"items"`
-	Likes       int    `json:"likes"`
-	UserID      int    `json:"user_id,omitempty"`
-	UserName    string `json:"user_name,omitempty"`
-	Dynamic     bool   `json:"dynamic,omitempty"`
-	Private     bool   `json:"private,omitempty"`
+	ID          int    `json:"id" yaml:"id"`
+	Name        string `json:"name" yaml:"name"`
+	Slug        string `json:"slug" yaml:"slug"`
+	Description string `json:"description" yaml:"description"`
+	MediaType   string `json:"mediatype" yaml:"mediatype"`
+	Items       int    `json:"items" yaml:"items"`
+	Likes       int    `json:"likes" yaml:"likes"`
+	UserID      int    `json:"user_id,omitempty" yaml:"user_id,omitempty"`
+	UserName    string `json:"user_name,omitempty" yaml:"user_name,omitempty"`
+	Dynamic     bool   `json:"dynamic,omitempty" yaml:"dynamic,omitempty"`
+	Private     bool   `json:"private,omitempty" yaml:"private,omitempty"`
 }
 
 // ListUpdateResponse represents the response from updating a list.
 type ListUpdateResponse struct {
-	Success    bool   `json:"success"`
-	ID         int    `json:"id,omitempty"`
-	UpdatedIDs []int  `json:"updated_ids,omitempty"`
-	Name       string `json:"name"`
+	Success    bool   `json:"success" yaml:"success"`
+	ID         int    `json:"id,omitempty" yaml:"id,omitempty"`
+	UpdatedIDs []int  `json:"updated_ids,omitempty" yaml:"updated_ids,omitempty"`
+	Name       string `json:"name" yaml:"name"`
 }
 
 // ListItems represents the items within a list, separated by media type.
 type ListItems struct {
-	Movies []ListItem `json:"movies"`
-	Shows  []ListItem `json:"shows"`
+	Movies []ListItem `json:"movies" yaml:"movies"`
+	Shows  []ListItem `json:"shows" yaml:"shows"`
+}
+
+// UnmarshalJSON also accepts the single array returned for unified=true,
+// splitting it into movies and shows by media type.
+func (items *ListItems) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var unified []ListItem
+		if err := json.Unmarshal(data, &unified); err != nil {
+			return err
+		}
+		*items = ListItems{Movies: []ListItem{}, Shows: []ListItem{}}
+		for _, item := range unified {
+			if item.MediaType == "show" {
+				items.Shows = append(items.Shows, item)
+			} else {
+				items.Movies = append(items.Movies, item)
+			}
+		}
+		return nil
+	}
+
+	type plain ListItems
+	return json.Unmarshal(data, (*plain)(items))
 }
 
 // ListItem represents a single movie or show in a list.
 type ListItem struct {
-	ID             int         `json:"id"`
-	Rank           int         `json:"rank"`
-	Adult          int         `json:"adult"`
-	Title          string      `json:"title"`
-	ImdbID         string      `json:"imdb_id"`
-	TvdbID         *int        `json:"tvdb_id"`
-	Language       string      `json:"language"`
-	MediaType      string      `json:"mediatype"`
-	ReleaseYear    int         `json:"release_year"`
-	SpokenLanguage string      `json:"spoken_language"`
-	Movies         []MediaItem `json:"movies,omitempty"`
-	Shows          []MediaItem `json:"shows,omitempty"`
+	ID             int         `json:"id" yaml:"id"`
+	Rank           int         `json:"rank" yaml:"rank"`
+	Adult          int         `json:"adult" yaml:"adult"`
+	Title          string      `json:"title" yaml:"title"`
+	ImdbID         string      `json:"imdb_id" yaml:"imdb_id"`
+	TvdbID         *int        `json:"tvdb_id" yaml:"tvdb_id"`
+	Language       string      `json:"language" yaml:"language"`
+	MediaType      string      `json:"mediatype" yaml:"mediatype"`
+	ReleaseYear    int         `json:"release_year" yaml:"release_year"`
+	SpokenLanguage string      `json:"spoken_language" yaml:"spoken_language"`
+	Movies         []MediaItem `json:"movies,omitempty" yaml:"movies,omitempty"`
+	Shows          []MediaItem `json:"shows,omitempty" yaml:"shows,omitempty"`
 }
 
 // ListChanges represents the changes in a list.
 type ListChanges struct {
-	ID    int `json:"id"`
+	ID    int `json:"id" yaml:"id"`
 	Movie struct {
 		TraktIDs struct {
-			Added   []int `json:"added"`
-			Removed []int `json:"removed"`
-		} `json:"trakt_ids"`
-	} `json:"movie"`
-	Updated time.Time `json:"updated"`
+			Added   []int `json:"added" yaml:"added"`
+			Removed []int `json:"removed" yaml:"removed"`
+		} `json:"trakt_ids" yaml:"trakt_ids"`
+	} `json:"movie" yaml:"movie"`
+	Updated time.Time `json:"updated" yaml:"updated"`
 }
 
 // MediaInfo represents detailed information about a media item.
 type MediaInfo struct {
-	Title           string `json:"title"`
-	Year            int    `json:"year"`
-	Released        string `json:"released"`
-	ReleasedDigital string `json:"released_digital"`
-	Description     string `json:"description"`
-	Runtime         int    `json:"runtime"`
-	Score           int    `json:"score"`
-	ScoreAverage    int    `json:"score_average"`
+	Title           string `json:"title" yaml:"title"`
+	Year            int    `json:"year" yaml:"year"`
+	Released        string `json:"released" yaml:"released"`
+	ReleasedDigital string `json:"released_digital" yaml:"released_digital"`
+	Description     string `json:"description" yaml:"description"`
+	Runtime         int    `json:"runtime" yaml:"runtime"`
+	Score           int    `json:"score" yaml:"score"`
+	ScoreAverage    int    `json:"score_average" yaml:"score_average"`
 	IDs             struct {
-		Imdb  string `json:"imdb"`
-		Trakt int    `json:"trakt"`
-		Tmdb  int    `json:"tmdb"`
-		Tvdb  *int   `json:"tvdb"`
-		Mal   *int   `json:"mal"`
-	} `json:"ids"`
-	Type    string `json:"type"`
-	Ratings []struct {
-		Source string      `json:"source"`
-		Value  interface{} `json:"value"` // Can be int or float
-		Score  *float64    `json:"score"`
-		Votes  *int        `json:"votes"`
-		URL    interface{} `json:"url"` // Can be int or string
-	} `json:"ratings"`
+		Imdb  string `json:"imdb" yaml:"imdb"`
+		Trakt int    `json:"trakt" yaml:"trakt"`
+		Tmdb  int    `json:"tmdb" yaml:"tmdb"`
+		Tvdb  *int   `json:"tvdb" yaml:"tvdb"`
+		Mal   *int   `json:"mal" yaml:"mal"`
+	} `json:"ids" yaml:"ids"`
+	Type    string   `json:"type" yaml:"type"`
+	Ratings []Rating `json:"ratings" yaml:"ratings"`
 	Streams []struct {
-		ID   int    `json:"id"`
-		Name string `json:"name"`
-	} `json:"streams"`
+		ID   int    `json:"id" yaml:"id"`
+		Name string `json:"name" yaml:"name"`
+	} `json:"streams" yaml:"streams"`
 	WatchProviders []struct {
-		ID   int    `json:"id"`
-		Name string `json:"name"`
-	} `json:"watch_providers"`
-	Language       string    `json:"language"`
-	SpokenLanguage string    `json:"spoken_language"`
-	Country        string    `json:"country"`
-	Certification  string    `json:"certification"`
-	Commonsense    *bool     `json:"commonsense"`
-	AgeRating      *int      `json:"age_rating"`
-	Status         string    `json:"status"`
-	Trailer        string    `json:"trailer"`
-	Poster         string    `json:"poster"`
-	Backdrop       string    `json:"backdrop"`
-	Reviews        []Review  `json:"reviews,omitempty"`
-	Keywords       []Keyword `json:"keywords,omitempty"`
+		ID   int    `json:"id" yaml:"id"`
+		Name string `json:"name" yaml:"name"`
+	} `json:"watch_providers" yaml:"watch_providers"`
+	Language       string    `json:"language" yaml:"language"`
+	SpokenLanguage string    `json:"spoken_language" yaml:"spoken_language"`
+	Country        string    `json:"country" yaml:"country"`
+	Certification  string    `json:"certification" yaml:"certification"`
+	Commonsense    *bool     `json:"commonsense" yaml:"commonsense"`
+	AgeRating      *int      `json:"age_rating" yaml:"age_rating"`
+	Status         string    `json:"status" yaml:"status"`
+	Trailer        string    `json:"trailer" yaml:"trailer"`
+	Poster         string    `json:"poster" yaml:"poster"`
+	Backdrop       string    `json:"backdrop" yaml:"backdrop"`
+	Reviews        []Review  `json:"reviews,omitempty" yaml:"reviews,omitempty"`
+	Keywords       []Keyword `json:"keywords,omitempty" yaml:"keywords,omitempty"`
+}
+
+// Rating represents the score of a media item on a single rating source.
+type Rating struct {
+	Source string      `json:"source" yaml:"source"`
+	Value  interface{} `json:"value" yaml:"value"` // Can be int or float
+	Score  *float64    `json:"score" yaml:"score"`
+	Votes  *int        `json:"votes" yaml:"votes"`
+	URL    interface{} `json:"url" yaml:"url"` // Can be int or string
 }
 
 type MediaItem struct {
-	TMDb int    `json:"tmdb,omitempty"`
-	IMDb string `json:"imdb,omitempty"`
+	TMDb int    `json:"tmdb,omitempty" yaml:"tmdb,omitempty"`
+	IMDb string `json:"imdb,omitempty" yaml:"imdb,omitempty"`
 }
 
 // Review represents a media review.
 type Review struct {
-	UpdatedAt  string `json:"updated_at"`
-	Author     string `json:"author"`
-	Rating     int    `json:"rating"`
-	ProviderID int    `json:"provider_id"`
-	Content    string `json:"content"`
+	UpdatedAt  string `json:"updated_at" yaml:"updated_at"`
+	Author     string `json:"author" yaml:"author"`
+	Rating     int    `json:"rating" yaml:"rating"`
+	ProviderID int    `json:"provider_id" yaml:"provider_id"`
+	Content    string `json:"content" yaml:"content"`
 }
 
 // Keyword represents a media keyword.
 type Keyword struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID   int    `json:"id" yaml:"id"`
+	Name string `json:"name" yaml:"name"`
 }
 
+// MediaInfoBatchLimit is the maximum number of IDs accepted by a single batch media info request.
+const MediaInfoBatchLimit = 200
+
 // MediaInfoBatchRequest represents the request body for a batch media info request.
 type MediaInfoBatchRequest struct {
-	IDs              []string `json:"ids"`
-	AppendToResponse []string `json:"append_to_response,omitempty"`
+	IDs              []string `json:"ids" yaml:"ids"`
+	AppendToResponse []string `json:"append_to_response,omitempty" yaml:"append_to_response,omitempty"`
 }
 
 // SearchResult represents the result of a media search.
 type SearchResult struct {
-	Search []struct {
-		Title        string `json:"title"`
-		Year         int    `json:"year"`
-		Score        int    `json:"score"`
-		ScoreAverage int    `json:"score_average"`
-		Type         string `json:"type"`
-		IDs          struct {
-			ImdbID  string `json:"imdbid"`
-			TmdbID  int    `json:"tmdbid"`
-			TraktID int    `json:"traktid"`
-			MalID   *int   `json:"malid"`
-			TvdbID  *int   `json:"tvdbid"`
-		} `json:"ids"`
-	} `json:"search"`
-	Total int `json:"total"`
+	Search []SearchItem `json:"search" yaml:"search"`
+	Total  int          `json:"total" yaml:"total"`
+}
+
+// SearchItem represents a single hit of a media search.
+type SearchItem struct {
+	Title        string `json:"title" yaml:"title"`
+	Year         int    `json:"year" yaml:"year"`
+	Score        int    `json:"score" yaml:"score"`
+	ScoreAverage int    `json:"score_average" yaml:"score_average"`
+	Type         string `json:"type" yaml:"type"`
+	IDs          struct {
+		ImdbID  string `json:"imdbid" yaml:"imdbid"`
+		TmdbID  int    `json:"tmdbid" yaml:"tmdbid"`
+		TraktID int    `json:"traktid" yaml:"traktid"`
+		MalID   *int   `json:"malid" yaml:"malid"`
+		TvdbID  *int   `json:"tvdbid" yaml:"tvdbid"`
+	} `json:"ids" yaml:"ids"`
 }
 
+// RatingsBatchLimit is the maximum number of IDs accepted by a single bulk ratings request.
+const RatingsBatchLimit = 200
+
 // RatingsRequest represents the request body for a bulk ratings request.
 type RatingsRequest struct {
-	IDs      []int  `json:"ids"`
-	Provider string `json:"provider"`
+	IDs      []int  `json:"ids" yaml:"ids"`
+	Provider string `json:"provider" yaml:"provider"`
 }
 
 // RatingsResponse represents the response from a bulk ratings request.
 type RatingsResponse struct {
-	ProviderID     string `json:"provider_id"`
-	ProviderRating string `json:"provider_rating"`
-	MediaType      string `json:"mediatype"`
-	Ratings        []struct {
-		ID     int     `json:"id"`
-		Rating float64 `json:"rating"`
-	} `json:"ratings"`
+	ProviderID     string        `json:"provider_id" yaml:"provider_id"`
+	ProviderRating string        `json:"provider_rating" yaml:"provider_rating"`
+	MediaType      string        `json:"mediatype" yaml:"mediatype"`
+	Ratings        []RatingScore `json:"ratings" yaml:"ratings"`
+}
+
+// RatingScore represents the rating of a single media item in a bulk ratings response.
+type RatingScore struct {
+	ID     int     `json:"id" yaml:"id"`
+	Rating float64 `json:"rating" yaml:"rating"`
 }
 
 // ModifyListRequest represents the request body for adding/removing items from a static list.
 type ModifyListRequest struct {
-	Movies []map[string]interface{} `json:"movies,omitempty"`
-	Shows  []map[string]interface{} `json:"shows,omitempty"`
+	Movies []map[string]interface{} `json:"movies,omitempty" yaml:"movies,omitempty"`
+	Shows  []map[string]interface{} `json:"shows,omitempty" yaml:"shows,omitempty"`
 }
 
 // ModifyListResponse represents the response from modifying a list.
 type ModifyListResponse struct {
 	Added struct {
-		Movies int `json:"movies"`
-		Shows  int `json:"shows"`
-	} `json:"added"`
+		Movies int `json:"movies" yaml:"movies"`
+		Shows  int `json:"shows" yaml:"shows"`
+	} `json:"added" yaml:"added"`
 	Existing struct {
-		Movies int `json:"movies"`
-		Shows  int `json:"shows"`
-	} `json:"existing"`
+		Movies int `json:"movies" yaml:"movies"`
+		Shows  int `json:"shows" yaml:"shows"`
+	} `json:"existing" yaml:"existing"`
 	NotFound struct {
-		Movies int `json:"movies"`
-		Shows  int `json:"shows"`
-	} `json:"not_found"`
+		Movies int `json:"movies" yaml:"movies"`
+		Shows  int `json:"shows" yaml:"shows"`
+	} `json:"not_found" yaml:"not_found"`
 }
 
 type ModifyListItemsResponse struct {
-	Added    map[string]int `json:"added"`
-	Existing map[string]int `json:"existing"`
-	NotFound map[string]int `json:"not_found"`
+	Added    map[string]int `json:"added" yaml:"added"`
+	Existing map[string]int `json:"existing" yaml:"existing"`
+	NotFound map[string]int `json:"not_found" yaml:"not_found"`
 }
 
 // LastActivities represents the last activity timestamps for sync purposes.
 type LastActivities struct {
-	WatchlistedAt time.Time `json:"watchlisted_at"`
+	WatchlistedAt time.Time `json:"watchlisted_at" yaml:"watchlisted_at"`
 }
 
 // WatchlistItems represents items in the user's watchlist.
 type WatchlistItems struct {
-	Movies []WatchlistItem `json:"movies"`
-	Shows  []WatchlistItem `json:"shows"`
+	Movies []WatchlistItem `json:"movies" yaml:"movies"`
+	Shows  []WatchlistItem `json:"shows" yaml:"shows"`
 }
 
 // WatchlistItem represents a single item in the watchlist.
 type WatchlistItem struct {
-	ListItem
-	WatchlistAt string `json:"watchlist_at"`
+	ListItem    `yaml:",inline"`
+	WatchlistAt string `json:"watchlist_at" yaml:"watchlist_at"`
 }
 
 // ModifyWatchlistResponse is an alias for ModifyListResponse as they share the same structure.
 type ModifyWatchlistResponse = ModifyListResponse
 
-// APIError represents an error response from the MDBList API.
-type APIError struct {
-	StatusCode int
-	Message    string
-}
-
-func (e *APIError) Error() string {
-	return fmt.Sprintf("API error (status %d): %s", e.StatusCode, e.Message)
+func (c *Client) ModifyListItems(listID int, action string, items ModifyListRequest) (*ModifyListItemsResponse, error) {
+	return c.ModifyListItemsContext(context.Background(), listID, action, items)
 }
 
-func (c *Client) ModifyListItems(listID int, action string, items ModifyListRequest) (*ModifyListItemsResponse, error) {
+func (c *Client) ModifyListItemsContext(ctx context.Context, listID int, action string, items ModifyListRequest) (*ModifyListItemsResponse, error) {
 	endpoint := fmt.Sprintf("/lists/%d/items/%s", listID, action)
 	var response ModifyListItemsResponse
-	if err := c.doRequest(http.MethodPost, endpoint, nil, items, &response); err != nil {
+	if err := c.doRequest(ctx, http.MethodPost, endpoint, nil, items, &response); err != nil {
 		return nil, err
 	}
 
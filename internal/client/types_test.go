@@ -0,0 +1,150 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package client_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+	"gopkg.in/yaml.v3"
+)
+
+func intPtr(n int) *int { return &n }
+
+func sampleListItems() client.ListItems {
+	return client.ListItems{
+		Movies: []client.ListItem{{
+			ID: 603, Rank: 1, Title: "The Matrix", ImdbID: "tt0133093", Language: "en",
+			MediaType: "movie", ReleaseYear: 1999, SpokenLanguage: "en",
+		}},
+		Shows: []client.ListItem{{
+			ID: 70523, Rank: 2, Title: "Dark", ImdbID: "tt5753856", TvdbID: intPtr(334824),
+			Language: "de", MediaType: "show", ReleaseYear: 2017, SpokenLanguage: "de",
+		}},
+	}
+}
+
+func sampleMediaInfo(t *testing.T) client.MediaInfo {
+	var info client.MediaInfo
+	must(t, json.Unmarshal([]byte(`{
+		"title": "The Matrix", "year": 1999, "released": "1999-03-30", "runtime": 136,
+		"score": 87, "score_average": 86,
+		"ids": {"imdb": "tt0133093", "trakt": 481, "tmdb": 603, "tvdb": null, "mal": 0},
+		"type": "movie",
+		"ratings": [
+			{"source": "imdb", "value": 8.7, "score": 87, "votes": 2000000, "url": "tt0133093"},
+			{"source": "metacritic", "value": 73, "score": null, "votes": null, "url": null}
+		],
+		"streams": [{"id": 8, "name": "Netflix"}],
+		"watch_providers": [{"id": 337, "name": "Disney Plus"}],
+		"language": "en", "certification": "R", "commonsense": true, "age_rating": 17,
+		"reviews": [{"updated_at": "2024-01-02", "author": "bob", "rating": 9, "provider_id": 1, "content": "Whoa."}],
+		"keywords": [{"id": 310, "name": "artificial intelligence"}]
+	}`), &info))
+	return info
+}
+
+func sampleModifyListResponse() client.ModifyListResponse {
+	var resp client.ModifyListResponse
+	resp.Added.Movies = 2
+	resp.Existing.Shows = 1
+	resp.NotFound.Movies = 3
+	return resp
+}
+
+// TestYAMLRoundTrip checks that the response types survive a YAML round trip
+// and are written with the same keys as the API's JSON.
+func TestYAMLRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   interface{}
+		out  interface{}
+	}{
+		{"ListItems", sampleListItems(), &client.ListItems{}},
+		{"MediaInfo", sampleMediaInfo(t), &client.MediaInfo{}},
+		{"ModifyListResponse", sampleModifyListResponse(), &client.ModifyListResponse{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := yaml.Marshal(tt.in)
+			must(t, err)
+			must(t, yaml.Unmarshal(data, tt.out))
+			// compared as JSON: YAML decodes the untyped rating values as
+			// ints where JSON has float64s
+			jsonData, err := json.Marshal(tt.in)
+			must(t, err)
+			back, err := json.Marshal(tt.out)
+			must(t, err)
+			if string(back) != string(jsonData) {
+				t.Errorf("round trip = %s\nwant %s\nYAML:\n%s", back, jsonData, data)
+			}
+
+			var fromYAML, fromJSON interface{}
+			must(t, yaml.Unmarshal(data, &fromYAML))
+			must(t, json.Unmarshal(jsonData, &fromJSON))
+			if got, want := keys(fromYAML), keys(fromJSON); !reflect.DeepEqual(got, want) {
+				t.Errorf("YAML keys = %v\nJSON keys = %v", got, want)
+			}
+		})
+	}
+}
+
+// keys lists the paths of all map keys in a decoded document.
+func keys(v interface{}) []string {
+	var out []string
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, child := range v {
+				out = append(out, prefix+k)
+				walk(prefix+k+".", child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(prefix+"[]", child)
+			}
+		}
+	}
+	walk("", v)
+	sort.Strings(out)
+	return out
+}
+
+func TestListItemsUnmarshalJSON(t *testing.T) {
+	want := sampleListItems()
+	split, err := json.Marshal(want)
+	must(t, err)
+	unified, err := json.Marshal(append(append([]client.ListItem{}, want.Shows...), want.Movies...))
+	must(t, err)
+
+	for name, data := range map[string][]byte{"split": split, "unified": unified} {
+		t.Run(name, func(t *testing.T) {
+			var got client.ListItems
+			must(t, json.Unmarshal(data, &got))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v\nwant %+v", got, want)
+			}
+
+			// what was decoded from JSON round-trips through YAML too
+			out, err := yaml.Marshal(got)
+			must(t, err)
+			var back client.ListItems
+			must(t, yaml.Unmarshal(out, &back))
+			if !reflect.DeepEqual(back, want) {
+				t.Errorf("YAML round trip = %+v\nwant %+v", back, want)
+			}
+		})
+	}
+
+	var empty client.ListItems
+	must(t, json.Unmarshal([]byte(" [] "), &empty))
+	if empty.Movies == nil || empty.Shows == nil || len(empty.Movies)+len(empty.Shows) != 0 {
+		t.Errorf("empty unified array = %+v, want empty movies and shows", empty)
+	}
+	if err := json.Unmarshal([]byte(`[{"id":"x"}]`), &empty); err == nil {
+		t.Error("expected an error for a malformed unified array")
+	}
+}
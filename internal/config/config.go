@@ -0,0 +1,183 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+
+// Package config reads and writes the mdblist-cli configuration file, which
+// holds named profiles:
+//
+//	current_profile: personal
+//	profiles:
+//	  personal:
+//	    api_key: abc123
+//	    output: table
+//	  bot:
+//	    api_key: def456
+//	    api_url: https://api.mdblist.com
+//	    list_id: 113124
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProfile is used when no profile is selected.
+const DefaultProfile = "default"
+
+// Keys lists the settings a profile can hold.
+var Keys = []string{"api_key", "api_url", "output", "list_id"}
+
+// Profile holds the settings of one account.
+type Profile struct {
+	APIKey string `yaml:"api_key,omitempty"`
+	APIURL string `yaml:"api_url,omitempty"`
+	Output string `yaml:"output,omitempty"`
+	// ListID is the list used by list commands when no list is given.
+	ListID int `yaml:"list_id,omitempty"`
+}
+
+// File is the content of the configuration file.
+type File struct {
+	CurrentProfile string              `yaml:"current_profile,omitempty"`
+	Profiles       map[string]*Profile `yaml:"profiles,omitempty"`
+}
+
+// DefaultPath returns $XDG_CONFIG_HOME/mdblist-cli/config.yaml, or
+// ~/.config/mdblist-cli/config.yaml when XDG_CONFIG_HOME is not set.
+func DefaultPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to locate home directory: %w", err)
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "mdblist-cli", "config.yaml"), nil
+}
+
+// Load reads the configuration file at path. A missing file yields an
+// empty configuration.
+func Load(path string) (*File, error) {
+	f := &File{}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := yaml.Unmarshal(b, f); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Save writes the configuration to path. The file holds API keys, so it is
+// only readable by the current user.
+func (f *File) Save(path string) error {
+	b, err := yaml.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// Current returns the name of the profile selected by current_profile.
+func (f *File) Current() string {
+	if f.CurrentProfile == "" {
+		return DefaultProfile
+	}
+	return f.CurrentProfile
+}
+
+// Profile returns the named profile, if it exists.
+func (f *File) Profile(name string) (*Profile, bool) {
+	p, ok := f.Profiles[name]
+	return p, ok && p != nil
+}
+
+// EnsureProfile returns the named profile, creating it if needed.
+func (f *File) EnsureProfile(name string) *Profile {
+	if p, ok := f.Profile(name); ok {
+		return p
+	}
+	if f.Profiles == nil {
+		f.Profiles = map[string]*Profile{}
+	}
+	p := &Profile{}
+	f.Profiles[name] = p
+	return p
+}
+
+// Names returns the profile names in alphabetical order.
+func (f *File) Names() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns a setting of the profile by key.
+func (p *Profile) Get(key string) (string, error) {
+	switch key {
+	case "api_key":
+		return p.APIKey, nil
+	case "api_url":
+		return p.APIURL, nil
+	case "output":
+		return p.Output, nil
+	case "list_id":
+		if p.ListID == 0 {
+			return "", nil
+		}
+		return strconv.Itoa(p.ListID), nil
+	}
+	return "", unknownKey(key)
+}
+
+// Set changes a setting of the profile by key. An empty value clears it.
+func (p *Profile) Set(key, value string) error {
+	switch key {
+	case "api_key":
+		p.APIKey = value
+	case "api_url":
+		p.APIURL = value
+	case "output":
+		p.Output = value
+	case "list_id":
+		if value == "" {
+			p.ListID = 0
+			return nil
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return fmt.Errorf("invalid list_id %q: must be a positive number", value)
+		}
+		p.ListID = id
+	default:
+		return unknownKey(key)
+	}
+	return nil
+}
+
+func unknownKey(key string) error {
+	return fmt.Errorf("unknown config key %q (valid keys: api_key, api_url, output, list_id)", key)
+}
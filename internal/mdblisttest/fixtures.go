@@ -0,0 +1,68 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package mdblisttest
+
+import (
+	"time"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+)
+
+// Sample IDs of the lists and media in SampleFixtures.
+const (
+	SampleUserID        = 7
+	SampleUserName      = "bob"
+	SampleStaticListID  = 1
+	SampleDynamicListID = 2
+
+	MatrixIMDb         = "tt0133093"
+	MatrixTMDb         = 603
+	MatrixReloadedIMDb = "tt0234215"
+	MatrixReloadedTMDb = 604
+	DarkIMDb           = "tt5753856"
+	DarkTMDb           = 70523
+)
+
+// SampleFixtures returns a small catalog for tests: a static list holding
+// The Matrix and Dark, a dynamic list, three titles with ratings and a
+// watchlist holding The Matrix Reloaded.
+func SampleFixtures() Fixtures {
+	matrix := media("The Matrix", "movie", 1999, MatrixIMDb, MatrixTMDb, 481, map[string]interface{}{"imdb": 8.7, "tomatoes": 83})
+	reloaded := media("The Matrix Reloaded", "movie", 2003, MatrixReloadedIMDb, MatrixReloadedTMDb, 482, map[string]interface{}{"imdb": 7.2, "tomatoes": 74})
+	dark := media("Dark", "show", 2017, DarkIMDb, DarkTMDb, 70523, map[string]interface{}{"imdb": 8.7})
+
+	return Fixtures{
+		Limits: client.MyLimits{APIRequests: 1000, UserID: SampleUserID, PatronStatus: "active_patron"},
+		Lists: []ListFixture{
+			{
+				List: client.List{ID: SampleStaticListID, Name: "Faves", Slug: "faves", MediaType: "movie", Items: 2, Likes: 3, UserID: SampleUserID, UserName: SampleUserName},
+				Items: client.ListItems{
+					Movies: []client.ListItem{toListItem(&matrix, 1)},
+					Shows:  []client.ListItem{toListItem(&dark, 2)},
+				},
+			},
+			{
+				List:  client.List{ID: SampleDynamicListID, Name: "Trending", Slug: "trending", MediaType: "movie", Likes: 50, UserID: SampleUserID, UserName: SampleUserName, Dynamic: true},
+				Items: client.ListItems{Movies: []client.ListItem{toListItem(&reloaded, 1)}, Shows: []client.ListItem{}},
+			},
+		},
+		Media: []client.MediaInfo{matrix, reloaded, dark},
+		Watchlist: client.WatchlistItems{
+			Movies: []client.WatchlistItem{{ListItem: toListItem(&reloaded, 1), WatchlistAt: "2024-01-02T03:04:05Z"}},
+			Shows:  []client.WatchlistItem{},
+		},
+		LastActivities: client.LastActivities{WatchlistedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}
+}
+
+func media(title, mediaType string, year int, imdb string, tmdb, trakt int, ratings map[string]interface{}) client.MediaInfo {
+	m := client.MediaInfo{Title: title, Type: mediaType, Year: year, Language: "en"}
+	m.IDs.Imdb = imdb
+	m.IDs.Tmdb = tmdb
+	m.IDs.Trakt = trakt
+	for _, source := range []string{"imdb", "tomatoes"} {
+		if v, ok := ratings[source]; ok {
+			m.Ratings = append(m.Ratings, client.Rating{Source: source, Value: v})
+		}
+	}
+	return m
+}
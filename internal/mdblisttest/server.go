@@ -0,0 +1,682 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+
+// Package mdblisttest provides an in-memory MDBList API server for tests.
+package mdblisttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+)
+
+// DefaultAPIKey is the key accepted by a Server created with an empty key.
+const DefaultAPIKey = "test-api-key"
+
+// DefaultLimit is the page size used when a request has no limit param, so
+// callers that ignore pagination only see the first page.
+const DefaultLimit = 100
+
+// ListFixture seeds a list together with its items.
+type ListFixture struct {
+	List  client.List      `json:"list"`
+	Items client.ListItems `json:"items"`
+}
+
+// Fixtures is the initial state of a Server. It can be loaded from JSON with
+// LoadFixtures.
+type Fixtures struct {
+	Limits         client.MyLimits       `json:"limits"`
+	Lists          []ListFixture         `json:"lists"`
+	Media          []client.MediaInfo    `json:"media"`
+	Watchlist      client.WatchlistItems `json:"watchlist"`
+	LastActivities client.LastActivities `json:"last_activities"`
+}
+
+// LoadFixtures decodes Fixtures from JSON.
+func LoadFixtures(r io.Reader) (Fixtures, error) {
+	var f Fixtures
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return f, fmt.Errorf("failed to decode fixtures: %w", err)
+	}
+	return f, nil
+}
+
+// Server is a fake MDBList API backed by httptest.Server. All state is kept
+// in memory and mutated by the modify endpoints.
+type Server struct {
+	*httptest.Server
+	APIKey string
+
+	mu       sync.Mutex
+	state    Fixtures
+	changes  map[int]*client.ListChanges
+	requests int
+	failures []failure
+}
+
+// failure is an error response queued by FailNext or FailAfter. A zero
+// status lets the request through.
+type failure struct {
+	status     int
+	message    string
+	retryAfter string
+}
+
+// NewServer starts a fake server seeded with f. An empty apiKey means
+// DefaultAPIKey. Callers must Close the server.
+func NewServer(apiKey string, f Fixtures) *Server {
+	if apiKey == "" {
+		apiKey = DefaultAPIKey
+	}
+	if f.Limits.APIRequests == 0 {
+		f.Limits.APIRequests = 1000
+	}
+	s := &Server{
+		APIKey:  apiKey,
+		state:   f,
+		changes: map[int]*client.ListChanges{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Client returns a client.Client talking to s.
+func (s *Server) Client(opts ...client.Option) (*client.Client, error) {
+	opts = append([]client.Option{client.WithBaseURL(s.URL)}, opts...)
+	return client.New(s.APIKey, opts...)
+}
+
+// Requests returns the number of authenticated requests served so far.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// FailNext makes the next n authenticated requests fail with status and an
+// MDBList error body carrying message, before they reach any endpoint.
+func (s *Server) FailNext(n, status int, message string) {
+	s.FailNextRetryAfter(n, status, message, "")
+}
+
+// FailNextRetryAfter is FailNext with a Retry-After header, as sent by
+// MDBList on 429 responses.
+func (s *Server) FailNextRetryAfter(n, status int, message, retryAfter string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, failure{status: status, message: message, retryAfter: retryAfter})
+	}
+}
+
+// FailAfter lets the next after requests through and makes the one following
+// them fail with status and message, e.g. to fail the second batch of a
+// modification.
+func (s *Server) FailAfter(after, status int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < after; i++ {
+		s.failures = append(s.failures, failure{})
+	}
+	s.failures = append(s.failures, failure{status: status, message: message})
+}
+
+// AddList seeds a list and its items.
+func (s *Server) AddList(list client.List, items client.ListItems) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Lists = append(s.state.Lists, ListFixture{List: list, Items: items})
+}
+
+// AddMedia seeds the media catalog used by media info, search, ratings and
+// the modify endpoints.
+func (s *Server) AddMedia(info client.MediaInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Media = append(s.state.Media, info)
+}
+
+// ListItems returns a copy of the current items of a list.
+func (s *Server) ListItems(listID int) client.ListItems {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l := s.listByID(listID); l != nil {
+		return client.ListItems{
+			Movies: append([]client.ListItem(nil), l.Items.Movies...),
+			Shows:  append([]client.ListItem(nil), l.Items.Shows...),
+		}
+	}
+	return client.ListItems{}
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("apikey") != s.APIKey {
+		writeError(w, http.StatusUnauthorized, "Invalid API key")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	s.state.Limits.APIRequestsCount++
+
+	if len(s.failures) > 0 {
+		f := s.failures[0]
+		s.failures = s.failures[1:]
+		if f.status != 0 {
+			if f.retryAfter != "" {
+				w.Header().Set("Retry-After", f.retryAfter)
+			}
+			writeError(w, f.status, f.message)
+			return
+		}
+	}
+
+	seg := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case match(r, http.MethodGet, seg, "user"):
+		writeJSON(w, s.state.Limits)
+	case match(r, http.MethodGet, seg, "lists", "user"):
+		writeJSON(w, s.userLists(strconv.Itoa(s.state.Limits.UserID)))
+	case match(r, http.MethodGet, seg, "lists", "user", "*"):
+		writeJSON(w, s.userLists(seg[2]))
+	case match(r, http.MethodGet, seg, "lists", "top"):
+		writeJSON(w, paginate(w, r.URL.Query(), s.topLists()))
+	case match(r, http.MethodGet, seg, "lists", "search"):
+		writeJSON(w, s.searchLists(r.URL.Query().Get("query")))
+	case match(r, http.MethodGet, seg, "sync", "last_activities"):
+		writeJSON(w, s.state.LastActivities)
+	case match(r, http.MethodGet, seg, "watchlist", "items"):
+		s.watchlistItems(w, r)
+	case match(r, http.MethodPost, seg, "watchlist", "items", "*"):
+		s.modifyWatchlist(w, r, seg[2])
+	case match(r, http.MethodGet, seg, "search", "*"):
+		result := s.searchMedia(seg[1], r.URL.Query().Get("query"))
+		result.Search = paginate(w, r.URL.Query(), result.Search)
+		writeJSON(w, result)
+	case match(r, http.MethodPost, seg, "rating", "*", "*"):
+		s.ratings(w, r, seg[1], seg[2])
+	case len(seg) >= 2 && seg[0] == "lists":
+		s.handleList(w, r, seg[1:])
+	case match(r, http.MethodGet, seg, "*", "*", "*"):
+		s.mediaInfo(w, seg[0], seg[1], seg[2])
+	case match(r, http.MethodPost, seg, "*", "*"):
+		s.mediaInfoBatch(w, r, seg[0], seg[1])
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// handleList serves /lists/{id}/... and /lists/{username}/{listname}/...
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request, seg []string) {
+	var (
+		fixture *ListFixture
+		rest    []string
+	)
+	if id, err := strconv.Atoi(seg[0]); err == nil && (len(seg) == 1 || seg[1] == "items" || seg[1] == "changes") {
+		fixture, rest = s.listByID(id), seg[1:]
+	} else if len(seg) >= 2 {
+		fixture, rest = s.listByName(seg[0], seg[1]), seg[2:]
+	}
+	if fixture == nil {
+		writeError(w, http.StatusNotFound, "List not found")
+		return
+	}
+
+	switch {
+	case match(r, http.MethodGet, rest):
+		writeJSON(w, []client.List{fixture.List})
+	case match(r, http.MethodPut, rest):
+		var payload struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Name == "" {
+			writeError(w, http.StatusBadRequest, "Invalid payload")
+			return
+		}
+		fixture.List.Name = payload.Name
+		writeJSON(w, client.ListUpdateResponse{Success: true, ID: fixture.List.ID, Name: payload.Name})
+	case match(r, http.MethodGet, rest, "items"):
+		s.listItems(w, r, fixture)
+	case match(r, http.MethodGet, rest, "changes"):
+		changes := s.changes[fixture.List.ID]
+		if changes == nil {
+			changes = &client.ListChanges{ID: fixture.List.ID}
+		}
+		writeJSON(w, changes)
+	case match(r, http.MethodPost, rest, "items", "*"):
+		if fixture.List.Dynamic {
+			writeError(w, http.StatusBadRequest, "List is not static")
+			return
+		}
+		s.modifyList(w, r, fixture, rest[1])
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// listItems serves a page of a list. Movies come before shows; limit and
+// offset apply to that combined sequence and X-Total-Items / X-Has-More
+// describe the page like the real API does.
+func (s *Server) listItems(w http.ResponseWriter, r *http.Request, fixture *ListFixture) {
+	q := r.URL.Query()
+	all := append(append([]client.ListItem{}, fixture.Items.Movies...), fixture.Items.Shows...)
+
+	sort.SliceStable(all, func(i, j int) bool {
+		a, b := all[i], all[j]
+		if a.MediaType != b.MediaType {
+			return a.MediaType != "show"
+		}
+		if q.Get("order") == "desc" {
+			a, b = b, a
+		}
+		switch q.Get("sort") {
+		case "title":
+			return a.Title < b.Title
+		case "released", "release_year", "year":
+			return a.ReleaseYear < b.ReleaseYear
+		}
+		return a.Rank < b.Rank
+	})
+
+	page := paginate(w, q, all)
+	if unified, _ := strconv.ParseBool(q.Get("unified")); unified {
+		writeJSON(w, page)
+		return
+	}
+	items := client.ListItems{Movies: []client.ListItem{}, Shows: []client.ListItem{}}
+	for _, item := range page {
+		if item.MediaType == "show" {
+			items.Shows = append(items.Shows, item)
+		} else {
+			items.Movies = append(items.Movies, item)
+		}
+	}
+	writeJSON(w, items)
+}
+
+func (s *Server) watchlistItems(w http.ResponseWriter, r *http.Request) {
+	all := append(append([]client.WatchlistItem{}, s.state.Watchlist.Movies...), s.state.Watchlist.Shows...)
+	items := client.WatchlistItems{Movies: []client.WatchlistItem{}, Shows: []client.WatchlistItem{}}
+	for _, item := range paginate(w, r.URL.Query(), all) {
+		if item.MediaType == "show" {
+			items.Shows = append(items.Shows, item)
+		} else {
+			items.Movies = append(items.Movies, item)
+		}
+	}
+	writeJSON(w, items)
+}
+
+func (s *Server) listByID(id int) *ListFixture {
+	for i := range s.state.Lists {
+		if s.state.Lists[i].List.ID == id {
+			return &s.state.Lists[i]
+		}
+	}
+	return nil
+}
+
+func (s *Server) listByName(username, listname string) *ListFixture {
+	for i := range s.state.Lists {
+		l := s.state.Lists[i].List
+		if l.UserName == username && (l.Slug == listname || l.Name == listname) {
+			return &s.state.Lists[i]
+		}
+	}
+	return nil
+}
+
+func (s *Server) userLists(user string) []client.List {
+	lists := []client.List{}
+	for _, f := range s.state.Lists {
+		if strconv.Itoa(f.List.UserID) == user || f.List.UserName == user {
+			lists = append(lists, f.List)
+		}
+	}
+	return lists
+}
+
+func (s *Server) topLists() []client.List {
+	lists := []client.List{}
+	for _, f := range s.state.Lists {
+		if !f.List.Private {
+			lists = append(lists, f.List)
+		}
+	}
+	sort.SliceStable(lists, func(i, j int) bool { return lists[i].Likes > lists[j].Likes })
+	return lists
+}
+
+func (s *Server) searchLists(query string) []client.List {
+	lists := []client.List{}
+	for _, l := range s.topLists() {
+		if containsFold(l.Name, query) {
+			lists = append(lists, l)
+		}
+	}
+	return lists
+}
+
+func (s *Server) searchMedia(mediaType, query string) client.SearchResult {
+	result := client.SearchResult{Search: []client.SearchItem{}}
+	for _, m := range s.state.Media {
+		if (mediaType != "any" && m.Type != mediaType) || !containsFold(m.Title, query) {
+			continue
+		}
+		item := client.SearchItem{
+			Title:        m.Title,
+			Year:         m.Year,
+			Score:        m.Score,
+			ScoreAverage: m.ScoreAverage,
+			Type:         m.Type,
+		}
+		item.IDs.ImdbID = m.IDs.Imdb
+		item.IDs.TmdbID = m.IDs.Tmdb
+		item.IDs.TraktID = m.IDs.Trakt
+		item.IDs.MalID = m.IDs.Mal
+		item.IDs.TvdbID = m.IDs.Tvdb
+		result.Search = append(result.Search, item)
+	}
+	result.Total = len(result.Search)
+	return result
+}
+
+// findMedia looks up the catalog by provider ID. An empty mediaType matches
+// both movies and shows.
+func (s *Server) findMedia(provider, mediaType, id string) *client.MediaInfo {
+	for i := range s.state.Media {
+		m := &s.state.Media[i]
+		if mediaType != "" && m.Type != mediaType {
+			continue
+		}
+		var candidate string
+		switch provider {
+		case "imdb":
+			candidate = m.IDs.Imdb
+		case "tmdb":
+			candidate = strconv.Itoa(m.IDs.Tmdb)
+		case "trakt":
+			candidate = strconv.Itoa(m.IDs.Trakt)
+		case "tvdb":
+			if m.IDs.Tvdb != nil {
+				candidate = strconv.Itoa(*m.IDs.Tvdb)
+			}
+		case "mal":
+			if m.IDs.Mal != nil {
+				candidate = strconv.Itoa(*m.IDs.Mal)
+			}
+		}
+		if candidate != "" && candidate == id {
+			return m
+		}
+	}
+	return nil
+}
+
+func (s *Server) mediaInfo(w http.ResponseWriter, provider, mediaType, id string) {
+	m := s.findMedia(provider, mediaType, id)
+	if m == nil {
+		writeError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+	writeJSON(w, m)
+}
+
+func (s *Server) mediaInfoBatch(w http.ResponseWriter, r *http.Request, provider, mediaType string) {
+	var req client.MediaInfoBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	infos := []client.MediaInfo{}
+	for _, id := range req.IDs {
+		if m := s.findMedia(provider, mediaType, id); m != nil {
+			infos = append(infos, *m)
+		}
+	}
+	writeJSON(w, infos)
+}
+
+func (s *Server) ratings(w http.ResponseWriter, r *http.Request, mediaType, returnRating string) {
+	var req client.RatingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	resp := client.RatingsResponse{
+		ProviderID:     req.Provider,
+		ProviderRating: returnRating,
+		MediaType:      mediaType,
+		Ratings:        []client.RatingScore{},
+	}
+	for _, id := range req.IDs {
+		m := s.findMedia(req.Provider, mediaType, strconv.Itoa(id))
+		if m == nil {
+			continue
+		}
+		for _, rating := range m.Ratings {
+			if rating.Source == returnRating {
+				resp.Ratings = append(resp.Ratings, client.RatingScore{ID: id, Rating: toFloat(rating.Value)})
+			}
+		}
+	}
+	writeJSON(w, resp)
+}
+
+// resolve finds the catalog entry referenced by an item of a ModifyListRequest.
+func (s *Server) resolve(mediaType string, ref map[string]interface{}) *client.MediaInfo {
+	for _, provider := range []string{"imdb", "tmdb", "trakt", "tvdb"} {
+		if v, ok := ref[provider]; ok {
+			return s.findMedia(provider, mediaType, fmt.Sprint(v))
+		}
+	}
+	return nil
+}
+
+func (s *Server) modifyList(w http.ResponseWriter, r *http.Request, fixture *ListFixture, action string) {
+	var req client.ModifyListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	if action != "add" && action != "remove" {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	changes := s.changes[fixture.List.ID]
+	if changes == nil {
+		changes = &client.ListChanges{ID: fixture.List.ID}
+		s.changes[fixture.List.ID] = changes
+	}
+	resp := client.ModifyListItemsResponse{
+		Added:    map[string]int{"movies": 0, "shows": 0},
+		Existing: map[string]int{"movies": 0, "shows": 0},
+		NotFound: map[string]int{"movies": 0, "shows": 0},
+	}
+	if action == "remove" {
+		// the API only reports not found items for removals
+		resp.Added = map[string]int{}
+		resp.Existing = map[string]int{}
+	}
+
+	apply := func(key, mediaType string, refs []map[string]interface{}, items *[]client.ListItem) {
+		for _, ref := range refs {
+			m := s.resolve(mediaType, ref)
+			if m == nil {
+				resp.NotFound[key]++
+				continue
+			}
+			idx := indexOf(*items, m)
+			switch {
+			case action == "add" && idx >= 0:
+				resp.Existing[key]++
+			case action == "add":
+				*items = append(*items, toListItem(m, len(*items)+1))
+				resp.Added[key]++
+				if mediaType == "movie" {
+					changes.Movie.TraktIDs.Added = append(changes.Movie.TraktIDs.Added, m.IDs.Trakt)
+				}
+			case idx >= 0:
+				*items = append((*items)[:idx], (*items)[idx+1:]...)
+				if mediaType == "movie" {
+					changes.Movie.TraktIDs.Removed = append(changes.Movie.TraktIDs.Removed, m.IDs.Trakt)
+				}
+			default:
+				resp.NotFound[key]++
+			}
+		}
+	}
+	apply("movies", "movie", req.Movies, &fixture.Items.Movies)
+	apply("shows", "show", req.Shows, &fixture.Items.Shows)
+
+	fixture.List.Items = len(fixture.Items.Movies) + len(fixture.Items.Shows)
+	changes.Updated = time.Now().UTC()
+	writeJSON(w, resp)
+}
+
+func (s *Server) modifyWatchlist(w http.ResponseWriter, r *http.Request, action string) {
+	var req client.ModifyListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid payload")
+		return
+	}
+	if action != "add" && action != "remove" {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	var resp client.ModifyWatchlistResponse
+	now := time.Now().UTC()
+	apply := func(mediaType string, refs []map[string]interface{}, items *[]client.WatchlistItem, added, existing, notFound *int) {
+		for _, ref := range refs {
+			m := s.resolve(mediaType, ref)
+			if m == nil {
+				*notFound++
+				continue
+			}
+			idx := -1
+			for i, it := range *items {
+				if it.ID == m.IDs.Tmdb {
+					idx = i
+				}
+			}
+			switch {
+			case action == "add" && idx >= 0:
+				*existing++
+			case action == "add":
+				*items = append(*items, client.WatchlistItem{ListItem: toListItem(m, len(*items)+1), WatchlistAt: now.Format(time.RFC3339)})
+				*added++
+			case idx >= 0:
+				*items = append((*items)[:idx], (*items)[idx+1:]...)
+			default:
+				*notFound++
+			}
+		}
+	}
+	apply("movie", req.Movies, &s.state.Watchlist.Movies, &resp.Added.Movies, &resp.Existing.Movies, &resp.NotFound.Movies)
+	apply("show", req.Shows, &s.state.Watchlist.Shows, &resp.Added.Shows, &resp.Existing.Shows, &resp.NotFound.Shows)
+
+	s.state.LastActivities.WatchlistedAt = now
+	writeJSON(w, resp)
+}
+
+func indexOf(items []client.ListItem, m *client.MediaInfo) int {
+	for i, it := range items {
+		if it.ID == m.IDs.Tmdb || (m.IDs.Imdb != "" && it.ImdbID == m.IDs.Imdb) {
+			return i
+		}
+	}
+	return -1
+}
+
+func toListItem(m *client.MediaInfo, rank int) client.ListItem {
+	return client.ListItem{
+		ID:             m.IDs.Tmdb,
+		Rank:           rank,
+		Title:          m.Title,
+		ImdbID:         m.IDs.Imdb,
+		TvdbID:         m.IDs.Tvdb,
+		Language:       m.Language,
+		MediaType:      m.Type,
+		ReleaseYear:    m.Year,
+		SpokenLanguage: m.SpokenLanguage,
+	}
+}
+
+// paginate applies the limit and offset params to items and sets the
+// X-Total-Items and X-Has-More headers.
+func paginate[T any](w http.ResponseWriter, q url.Values, items []T) []T {
+	total := len(items)
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	if offset > total {
+		offset = total
+	}
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = DefaultLimit
+	}
+	end := total
+	if offset+limit < total {
+		end = offset + limit
+	}
+	w.Header().Set("X-Total-Items", strconv.Itoa(total))
+	w.Header().Set("X-Has-More", strconv.FormatBool(end < total))
+	return items[offset:end]
+}
+
+// match reports whether the request has the given method and its path
+// segments equal want, where "*" matches any single segment.
+func match(r *http.Request, method string, seg []string, want ...string) bool {
+	if len(seg) == 1 && seg[0] == "" {
+		seg = nil
+	}
+	if r.Method != method || len(seg) != len(want) {
+		return false
+	}
+	for i, w := range want {
+		if w != "*" && w != seg[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	case string:
+		f, _ := strconv.ParseFloat(n, 64)
+		return f
+	}
+	return 0
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,111 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package mdblisttest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+	"github.com/luckylittle/mdblist-cli/internal/mdblisttest"
+)
+
+// get sends a GET request for path with the given API key and decodes the
+// MDBList error body, if any.
+func get(t *testing.T, srv *mdblisttest.Server, key, path string) (*http.Response, string) {
+	t.Helper()
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	resp, err := http.Get(srv.URL + path + sep + "apikey=" + key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp, body.Error
+}
+
+func TestServerRequiresAPIKey(t *testing.T) {
+	srv := mdblisttest.NewServer("", mdblisttest.SampleFixtures())
+	defer srv.Close()
+
+	if srv.APIKey != mdblisttest.DefaultAPIKey {
+		t.Errorf("APIKey = %q, want %q", srv.APIKey, mdblisttest.DefaultAPIKey)
+	}
+	resp, msg := get(t, srv, "wrong", "/user")
+	if resp.StatusCode != http.StatusUnauthorized || msg != "Invalid API key" {
+		t.Errorf("wrong key: status %d, error %q", resp.StatusCode, msg)
+	}
+	if srv.Requests() != 0 {
+		t.Errorf("Requests() = %d, rejected requests must not count", srv.Requests())
+	}
+	if resp, _ := get(t, srv, srv.APIKey, "/user"); resp.StatusCode != http.StatusOK || srv.Requests() != 1 {
+		t.Errorf("right key: status %d, %d requests", resp.StatusCode, srv.Requests())
+	}
+}
+
+func TestServerFailures(t *testing.T) {
+	srv := mdblisttest.NewServer("", mdblisttest.SampleFixtures())
+	defer srv.Close()
+
+	srv.FailNextRetryAfter(1, http.StatusTooManyRequests, "Slow down", "7")
+	srv.FailAfter(1, http.StatusBadGateway, "Bad gateway")
+	tests := []struct {
+		status     int
+		message    string
+		retryAfter string
+	}{
+		{http.StatusTooManyRequests, "Slow down", "7"},
+		{http.StatusOK, "", ""},
+		{http.StatusBadGateway, "Bad gateway", ""},
+		{http.StatusOK, "", ""},
+	}
+	for i, tt := range tests {
+		resp, msg := get(t, srv, srv.APIKey, "/lists/user")
+		if resp.StatusCode != tt.status || msg != tt.message || resp.Header.Get("Retry-After") != tt.retryAfter {
+			t.Errorf("request %d: status %d, error %q, Retry-After %q; want %d, %q, %q", i+1,
+				resp.StatusCode, msg, resp.Header.Get("Retry-After"), tt.status, tt.message, tt.retryAfter)
+		}
+	}
+}
+
+func TestServerState(t *testing.T) {
+	srv := mdblisttest.NewServer("", mdblisttest.SampleFixtures())
+	defer srv.Close()
+	c, err := srv.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp, msg := get(t, srv, srv.APIKey, "/lists/999"); resp.StatusCode != http.StatusNotFound || msg != "List not found" {
+		t.Errorf("unknown list: status %d, error %q", resp.StatusCode, msg)
+	}
+
+	resp, _ := get(t, srv, srv.APIKey, "/lists/1/items?limit=1")
+	if resp.Header.Get("X-Total-Items") != "2" || resp.Header.Get("X-Has-More") != "true" {
+		t.Errorf("pagination headers: total %q, has more %q", resp.Header.Get("X-Total-Items"), resp.Header.Get("X-Has-More"))
+	}
+
+	req := client.ModifyListRequest{Movies: []map[string]interface{}{{"imdb": mdblisttest.MatrixReloadedIMDb}}}
+	if _, err := c.ModifyListItems(mdblisttest.SampleStaticListID, "add", req); err != nil {
+		t.Fatal(err)
+	}
+	if items := srv.ListItems(mdblisttest.SampleStaticListID); len(items.Movies) != 2 || items.Movies[1].ImdbID != mdblisttest.MatrixReloadedIMDb {
+		t.Errorf("ListItems after add = %+v", items)
+	}
+	if _, err := c.ModifyListItems(mdblisttest.SampleDynamicListID, "add", req); err == nil {
+		t.Error("modifying a dynamic list should fail")
+	}
+
+	srv.AddMedia(client.MediaInfo{Title: "Added", Type: "movie"})
+	srv.AddList(client.List{ID: 9, Name: "New"}, client.ListItems{})
+	if lists, err := c.GetListByID(9); err != nil || len(lists) != 1 || lists[0].Name != "New" {
+		t.Errorf("GetListByID(9) = %+v, %v", lists, err)
+	}
+}
@@ -0,0 +1,218 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+
+// Package snapshot stores timestamped copies of list items on disk, one
+// directory per list and one JSON file per snapshot, and compares them.
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+)
+
+// fileTimeFormat names snapshot files, so they sort by time. Snapshots taken
+// within the same second get distinct names.
+const fileTimeFormat = "20060102T150405.000000000Z"
+
+// legacyFileTimeFormat named snapshot files before they had nanoseconds.
+const legacyFileTimeFormat = "20060102T150405Z"
+
+// ErrNoSnapshot is returned when no snapshot matches a request.
+var ErrNoSnapshot = errors.New("no snapshot found")
+
+// Snapshot is the content of a list at one point in time.
+type Snapshot struct {
+	ListID   int              `json:"list_id"`
+	ListName string           `json:"list_name"`
+	TakenAt  time.Time        `json:"taken_at"`
+	Items    client.ListItems `json:"items"`
+}
+
+// Info describes a stored snapshot without its items.
+type Info struct {
+	ListID   int       `json:"list_id" yaml:"list_id"`
+	ListName string    `json:"list_name" yaml:"list_name"`
+	TakenAt  time.Time `json:"taken_at" yaml:"taken_at"`
+	Movies   int       `json:"movies" yaml:"movies"`
+	Shows    int       `json:"shows" yaml:"shows"`
+	Path     string    `json:"path" yaml:"path"`
+}
+
+// Store keeps snapshots below Dir.
+type Store struct {
+	Dir string
+}
+
+// DefaultDir returns $XDG_DATA_HOME/mdblist-cli/snapshots, or
+// ~/.local/share/mdblist-cli/snapshots when XDG_DATA_HOME is not set.
+func DefaultDir() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to locate home directory: %w", err)
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "mdblist-cli", "snapshots"), nil
+}
+
+func (s Store) listDir(listID int) string {
+	return filepath.Join(s.Dir, strconv.Itoa(listID))
+}
+
+// Save writes a snapshot and returns its path.
+func (s Store) Save(snap *Snapshot) (string, error) {
+	dir := s.listDir(snap.ListID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	b, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	path := filepath.Join(dir, snap.TakenAt.UTC().Format(fileTimeFormat)+".json")
+	// never overwrite another snapshot taken at the same time
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	_, err = f.Write(b)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return path, nil
+}
+
+// isSnapshotFile reports whether name was given by Save.
+func isSnapshotFile(name string) bool {
+	stamp, ok := strings.CutSuffix(name, ".json")
+	if !ok {
+		return false
+	}
+	for _, layout := range []string{fileTimeFormat, legacyFileTimeFormat} {
+		if _, err := time.Parse(layout, stamp); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Load reads the snapshot at path.
+func (s Store) Load(path string) (*Snapshot, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+	return &snap, nil
+}
+
+// List returns the snapshots of a list, oldest first.
+func (s Store) List(listID int) ([]Info, error) {
+	entries, err := os.ReadDir(s.listDir(listID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	var infos []Info
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !isSnapshotFile(name) {
+			continue
+		}
+		path := filepath.Join(s.listDir(listID), name)
+		snap, err := s.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, Info{
+			ListID:   snap.ListID,
+			ListName: snap.ListName,
+			TakenAt:  snap.TakenAt,
+			Movies:   len(snap.Items.Movies),
+			Shows:    len(snap.Items.Shows),
+			Path:     path,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].TakenAt.Before(infos[j].TakenAt) })
+	return infos, nil
+}
+
+// At returns the latest snapshot of a list taken at or before t.
+func (s Store) At(listID int, t time.Time) (*Info, error) {
+	infos, err := s.List(listID)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(infos) - 1; i >= 0; i-- {
+		if !infos[i].TakenAt.After(t) {
+			return &infos[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w for list %d at or before %s", ErrNoSnapshot, listID, t.Format(time.RFC3339))
+}
+
+// Diff lists the items added and removed between two snapshots, movies
+// before shows.
+type Diff struct {
+	Added   []client.ListItem
+	Removed []client.ListItem
+}
+
+// Compare returns the changes from one snapshot to another. Items are
+// matched by media type and ID.
+func Compare(from, to *Snapshot) Diff {
+	key := func(item client.ListItem, mediaType string) string {
+		if item.ID > 0 {
+			return mediaType + ":" + strconv.Itoa(item.ID)
+		}
+		return mediaType + ":" + item.ImdbID
+	}
+	index := func(snap *Snapshot) map[string]bool {
+		keys := map[string]bool{}
+		for _, item := range snap.Items.Movies {
+			keys[key(item, "movie")] = true
+		}
+		for _, item := range snap.Items.Shows {
+			keys[key(item, "show")] = true
+		}
+		return keys
+	}
+	missing := func(items []client.ListItem, mediaType string, in map[string]bool) []client.ListItem {
+		var out []client.ListItem
+		for _, item := range items {
+			if !in[key(item, mediaType)] {
+				if item.MediaType == "" {
+					item.MediaType = mediaType
+				}
+				out = append(out, item)
+			}
+		}
+		return out
+	}
+
+	fromKeys, toKeys := index(from), index(to)
+	var d Diff
+	d.Added = append(missing(to.Items.Movies, "movie", fromKeys), missing(to.Items.Shows, "show", fromKeys)...)
+	d.Removed = append(missing(from.Items.Movies, "movie", toKeys), missing(from.Items.Shows, "show", toKeys)...)
+	return d
+}
@@ -0,0 +1,91 @@
+// Maintainer: Lucian Maly <lmaly@redhat.com>
+package snapshot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luckylittle/mdblist-cli/internal/client"
+)
+
+func TestSaveWithinOneSecond(t *testing.T) {
+	store := Store{Dir: t.TempDir()}
+	taken := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var paths []string
+	for _, offset := range []time.Duration{0, time.Millisecond, time.Nanosecond} {
+		path, err := store.Save(&Snapshot{ListID: 1, TakenAt: taken.Add(offset)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, filepath.Base(path))
+	}
+	want := []string{"20240501T120000.000000000Z.json", "20240501T120000.001000000Z.json", "20240501T120000.000000001Z.json"}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("snapshot %d saved as %s, want %s", i, paths[i], want[i])
+		}
+	}
+
+	infos, err := store.List(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 3 || !infos[1].TakenAt.Equal(taken.Add(time.Nanosecond)) {
+		t.Errorf("List = %+v", infos)
+	}
+}
+
+func TestSaveDoesNotOverwrite(t *testing.T) {
+	store := Store{Dir: t.TempDir()}
+	taken := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	first := &Snapshot{ListID: 1, ListName: "first", TakenAt: taken}
+	path, err := store.Save(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Save(&Snapshot{ListID: 1, ListName: "second", TakenAt: taken}); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("second Save at the same time: err = %v, want os.ErrExist", err)
+	}
+	snap, err := store.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.ListName != "first" {
+		t.Errorf("the first snapshot was overwritten by %q", snap.ListName)
+	}
+}
+
+func TestListReadsLegacyNames(t *testing.T) {
+	store := Store{Dir: t.TempDir()}
+	legacy := time.Date(2024, 4, 30, 8, 0, 0, 0, time.UTC)
+	current := legacy.Add(36 * time.Hour)
+
+	dir := filepath.Join(store.Dir, "1")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"20240430T080000Z.json": `{"list_id": 1, "taken_at": "2024-04-30T08:00:00Z", "items": {"movies": [], "shows": [{"id": 70523}]}}`,
+		"notes.json":            `not a snapshot`,
+		"20240430T080000Z.txt":  `not a snapshot`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Save(&Snapshot{ListID: 1, TakenAt: current, Items: client.ListItems{Movies: []client.ListItem{{ID: 603}}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := store.List(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || !infos[0].TakenAt.Equal(legacy) || infos[0].Shows != 1 || !infos[1].TakenAt.Equal(current) || infos[1].Movies != 1 {
+		t.Errorf("List = %+v", infos)
+	}
+}